@@ -0,0 +1,188 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"html/template"
+	"io/fs"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+)
+
+const ginReloadTopic = "reload"
+
+type ginReloader struct {
+	pubsub *PubSub[string, string]
+	stop   chan struct{}
+	once   sync.Once
+}
+
+func newGinReloader() *ginReloader {
+	return &ginReloader{pubsub: NewPubSub[string, string](), stop: make(chan struct{})}
+}
+
+// Close 停止调试模式下的文件监听
+func (r *Gin) Close() {
+	if r.reloader != nil {
+		r.reloader.once.Do(func() {
+			close(r.reloader.stop)
+		})
+	}
+}
+
+// watch 轮询模板及静态文件目录, 模板修改后清空模板缓存, 静态文件按修改时间自动失效;
+// 只监听已设置的目录, 模板目录只比较模板文件, 两个目录均忽略隐藏文件及编辑器的临时文件
+func (r *Gin) watch(interval time.Duration) {
+	if r.config.TemplatePathBase == "" && r.config.StaticPathBase == "" {
+		return
+	}
+	files, _ := r.getAllTemplateFiles(r.config.TemplatePathBase)
+	var (
+		exts       = ginTemplateExts(files)
+		isTemplate = func(name string) bool { return ginIsTemplateFile(name, exts) }
+		templates  = ginDirSignature(r.config.TemplatePathBase, isTemplate)
+		statics    = ginDirSignature(r.config.StaticPathBase, ginIsWatchedFile)
+		ticker     = time.NewTicker(interval)
+	)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.reloader.stop:
+			return
+		case <-ticker.C:
+		}
+		if v := ginDirSignature(r.config.TemplatePathBase, isTemplate); v != templates {
+			templates = v
+			r.resetTemplates()
+			r.reloader.pubsub.Publish(ginReloadTopic, "template")
+		}
+		if v := ginDirSignature(r.config.StaticPathBase, ginIsWatchedFile); v != statics {
+			statics = v
+			r.reloader.pubsub.Publish(ginReloadTopic, "static")
+		}
+	}
+}
+
+// ginDirSignature 由目录下 match 的文件的路径、大小及修改时间计算, 不进入隐藏目录, 目录为空时为 0
+func ginDirSignature(dir string, match func(name string) bool) uint64 {
+	if dir == "" {
+		return 0
+	}
+	h := fnv.New64a()
+	_ = filepath.WalkDir(dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name = filepath.ToSlash(name); !match(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		_, _ = h.Write([]byte(name + "|" + strconv.FormatInt(info.Size(), 10) + "|" + strconv.FormatInt(info.ModTime().UnixNano(), 10) + "\n"))
+		return nil
+	})
+	return h.Sum64()
+}
+
+// ginIsWatchedFile 忽略隐藏文件(如 .DS_Store、.*.swp)及编辑器的备份文件(*~)
+func ginIsWatchedFile(name string) bool {
+	base := path.Base(name)
+	return !strings.HasPrefix(base, ".") && !strings.HasSuffix(base, "~")
+}
+
+// serveReload 以 SSE 推送 reload 事件, 数据为 template 或 static
+func (r *Gin) serveReload(c *gin.Context) {
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	events := r.reloader.pubsub.Subscribe(ctx, ginReloadTopic)
+	keepalive := time.NewTicker(15 * time.Second)
+	defer keepalive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.reloader.stop:
+			return
+		case event := <-events:
+			c.SSEvent("reload", event.Data)
+		case <-keepalive.C:
+			_, _ = c.Writer.WriteString(": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+// reloadScript 调试模式下输出监听 reload 事件并刷新页面的脚本, 发布模式下为空
+func (r *Gin) reloadScript() template.HTML {
+	if !r.config.Debug {
+		return ""
+	}
+	return template.HTML(`<script>new EventSource(` + strconv.Quote(r.config.ReloadPath) + `).addEventListener("reload",function(){location.reload()})</script>`)
+}
+
+// ginHTML 先渲染到缓冲区, 模板解析或执行出错时输出错误页面, 调试模式下包含错误详情
+type ginHTML struct {
+	render.HTML
+	server *Gin
+	page   string
+	err    error
+}
+
+func (h ginHTML) Render(w http.ResponseWriter) error {
+	if h.err == nil {
+		var buf bytes.Buffer
+		if h.err = h.Template.ExecuteTemplate(&buf, h.Name, h.Data); h.err == nil {
+			h.WriteContentType(w)
+			_, err := buf.WriteTo(w)
+			return err
+		}
+	}
+
+	h.WriteContentType(w)
+	w.WriteHeader(http.StatusInternalServerError)
+	if !h.server.config.Debug {
+		_, _ = w.Write([]byte(http.StatusText(http.StatusInternalServerError)))
+		return h.err
+	}
+	_ = ginErrorPage.Execute(w, map[string]any{
+		"Name":   h.page,
+		"Error":  h.err.Error(),
+		"Reload": h.server.reloadScript(),
+	})
+	return h.err
+}
+
+var ginErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Template Error</title></head>
+<body style="font-family:monospace;margin:2em">
+<h2 style="color:#c00">Template error: {{.Name}}</h2>
+<pre style="white-space:pre-wrap;background:#fee;padding:1em;border:1px solid #c00">{{.Error}}</pre>
+<p>The page will reload when the template is fixed.</p>
+{{.Reload}}
+</body>
+</html>`))
@@ -2,22 +2,29 @@ package utils
 
 import (
 	"embed"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/render"
 	"html/template"
 	"io"
 	"io/fs"
 	"io/ioutil"
-	"net/http"
 	"os"
-	"strconv"
+	"path"
 	"strings"
 	"time"
 )
 
 type GinConfig struct {
-	TemplateEmbed    embed.FS
+	//局部模板(_*.html)需通过 //go:embed all:目录 嵌入
+	TemplateEmbed embed.FS
+	//调试模式下同时加载该目录中硬盘上的模板文件, 为空时只使用嵌入的模板
 	TemplatePathBase string
+	//默认布局文件, 布局中通过 {{block "content" .}}{{end}} 引用页面定义的块
+	TemplateLayout string
+	//目录 => 布局文件, 按最长目录前缀匹配, 优先于 TemplateLayout, 布局为 "-" 时该目录不使用布局
+	TemplateLayouts map[string]string
 
 	StaticEmbed    embed.FS
 	StaticPathBase string
@@ -25,15 +32,25 @@ type GinConfig struct {
 
 	StaticMiddlewares []gin.HandlerFunc
 
-	CacheMinute  int
-	Debug        bool
+	CacheMinute int
+	//带内容指纹的文件名(如 app.3f2a9c1b.js)使用 immutable 长期缓存
+	StaticImmutable bool
+
+	Debug bool
+	//调试模式下轮询模板及静态文件目录的间隔, 默认 500ms, 目录修改后重新加载模板并通过 ReloadPath 通知浏览器
+	ReloadInterval time.Duration
+	//调试模式下的 SSE 地址, 默认 /__reload, 模板中使用 {{ reloadScript }} 在文件修改后自动刷新页面
+	ReloadPath string
+
 	AccessLogger io.Writer
 	ErrorLogger  io.Writer
 }
 
 type Gin struct {
-	config *GinConfig
-	tmpl   *template.Template
+	config    *GinConfig
+	templates *ginTemplates
+	assets    *ginAssets
+	reloader  *ginReloader
 	*gin.Engine
 }
 
@@ -82,107 +99,139 @@ func newGinServer(engine *gin.Engine, c GinConfig) *Gin {
 	c.StaticPathBase = strings.Trim(c.StaticPathBase, "/")
 	c.StaticUrlBase = strings.Trim(c.StaticUrlBase, "/")
 	c.TemplatePathBase = strings.Trim(c.TemplatePathBase, "/")
+	if c.ReloadInterval <= 0 {
+		c.ReloadInterval = 500 * time.Millisecond
+	}
+	if c.ReloadPath == "" {
+		c.ReloadPath = "/__reload"
+	}
 
-	server := &Gin{config: &c, Engine: engine}
+	server := &Gin{config: &c, templates: newGinTemplates(), assets: newGinAssets(), Engine: engine}
+	if err := server.buildAssetManifest(); err != nil {
+		_, _ = fmt.Fprintf(gin.DefaultErrorWriter, "[WARNING] build asset manifest: %v\n", err)
+	}
+	if engine.FuncMap == nil {
+		engine.FuncMap = template.FuncMap{}
+	}
+	engine.FuncMap["asset"] = server.AssetURL
+	engine.FuncMap["reloadScript"] = server.reloadScript
 	server.serve()
+	if c.Debug {
+		server.reloader = newGinReloader()
+		server.GET(c.ReloadPath, server.serveReload)
+		go server.watch(c.ReloadInterval)
+	}
 	return server
 }
 
 func (r *Gin) serve() {
-	var (
-		lastModify  string
-		assetGroup  *gin.RouterGroup
-		middlewares = make([]gin.HandlerFunc, 0)
-	)
-
 	r.HTMLRender = r
 
-	lastModify = time.Now().UTC().Format(time.RFC1123)
-
-	middlewares = append(middlewares, r.config.StaticMiddlewares...)
-	middlewares = append(middlewares, func(c *gin.Context) {
-		if r.config.CacheMinute > 0 {
-			c.Header("Expires", time.Now().UTC().Add(time.Minute*time.Duration(r.config.CacheMinute)).Format(time.RFC1123))
-			c.Header("Cache-Control", "max-age="+strconv.Itoa(r.config.CacheMinute*60)+", must-revalidate")
-		} else {
-			c.Header("Cache-Control", "no-cache")
-		}
-		c.Header("Last-Modified", lastModify)
-		last := c.Request.Header.Get("If-Modified-Since")
-		if len(last) > 0 && last == lastModify {
-			c.Status(http.StatusNotModified)
-			c.Abort()
-		}
-	})
-
-	assetGroup = r.Group(r.config.StaticUrlBase)
-	if len(middlewares) > 0 {
-		assetGroup.Use(middlewares...)
+	assetGroup := r.Group(r.config.StaticUrlBase)
+	if len(r.config.StaticMiddlewares) > 0 {
+		assetGroup.Use(r.config.StaticMiddlewares...)
 	}
-	assetGroup.StaticFS("/", http.FS(r))
+	assetGroup.GET("/*filepath", r.serveStatic)
+	assetGroup.HEAD("/*filepath", r.serveStatic)
 }
 
 func (r *Gin) Instance(filename string, data interface{}) render.Render {
-	var (
-		err  error
-		tmpl *template.Template
-	)
-	if r.config.Debug {
-		if tmpl, err = r.loadTemplate(filename); err != nil {
-			panic(err)
-		}
-	} else {
-		if r.tmpl == nil {
-			if r.tmpl, err = r.loadPathTemplates(r.config.TemplatePathBase); err != nil {
-				panic(err)
-			}
-		}
-		tmpl = r.tmpl
+	return r.instance(filename, r.layoutFor(filename), data)
+}
+
+// HTMLWithLayout 使用指定布局渲染页面, layout 为空时不使用布局
+func (r *Gin) HTMLWithLayout(c *gin.Context, code int, layout, filename string, data interface{}) {
+	c.Render(code, r.instance(filename, layout, data))
+}
+
+// instance 模板解析错误不再 panic, 由 ginHTML 输出错误页面
+func (r *Gin) instance(filename, layout string, data interface{}) render.Render {
+	tmpl, err := r.pageTemplate(filename, layout)
+	name := filename
+	if layout != "" {
+		name = layout
 	}
-	return render.HTML{
-		Template: tmpl,
-		Name:     filename,
-		Data:     data,
+	return ginHTML{
+		HTML: render.HTML{
+			Template: tmpl,
+			Name:     name,
+			Data:     data,
+		},
+		server: r,
+		page:   filename,
+		err:    err,
 	}
 }
 
-func (r *Gin) loadTemplate(name string) (*template.Template, error) {
-	return r.parseTemplates([]string{name})
-}
 func (r *Gin) loadPathTemplates(path string) (*template.Template, error) {
 	var (
 		err   error
 		files []string
 	)
-	if files, err = r.getAllTemplateFiles(path); err != nil {
+	if files, err = r.getAllTemplateFiles(path); err != nil && !(r.config.Debug && errors.Is(err, fs.ErrNotExist)) {
 		return nil, err
 	}
+	//调试模式下包含硬盘上新增的文件
+	if r.config.Debug {
+		files = r.appendDiskTemplateFiles(files)
+	}
 	return r.parseTemplates(files)
 }
 func (r *Gin) parseTemplates(files []string) (*template.Template, error) {
 	var (
 		err   error
 		bytes []byte
-		tmpl  = template.New("").Funcs(r.FuncMap)
+		funcs = r.templateFuncs()
+		tmpl  = template.New("").Funcs(funcs)
 	)
 	for _, name := range files {
-		bytes = nil
-		//调试模式下尽可能加载硬盘文件
-		if r.config.Debug {
-			bytes, _ = ioutil.ReadFile(r.config.TemplatePathBase + "/" + name)
+		if bytes, err = r.readTemplate(name); err != nil {
+			return nil, err
 		}
-		if bytes == nil {
-			if bytes, err = r.config.TemplateEmbed.ReadFile(r.config.TemplatePathBase + "/" + name); err != nil {
+		//页面单独解析后只加入主体, 其中 {{define}} 的块不进入共享模板集, 避免页面之间的同名块互相覆盖
+		if !ginIsPartial(name) && !r.isLayout(name) {
+			page, err := template.New(name).Funcs(funcs).Parse(string(bytes))
+			if err != nil {
 				return nil, err
 			}
+			if tmpl, err = tmpl.AddParseTree(name, page.Tree); err != nil {
+				return nil, err
+			}
+			continue
 		}
 		if tmpl, err = tmpl.New(name).Parse(string(bytes)); err != nil {
 			return nil, err
 		}
+		//局部模板同时以去掉 "_" 前缀和扩展名的名称注册, 如 partials/_nav.html => nav
+		if ginIsPartial(name) {
+			if tmpl, err = tmpl.AddParseTree(ginPartialName(name), tmpl.Lookup(name).Tree); err != nil {
+				return nil, err
+			}
+		}
 	}
 	return tmpl, nil
 }
 
+func (r *Gin) templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{"asset": r.AssetURL, "reloadScript": r.reloadScript}
+	for k, v := range r.FuncMap {
+		funcs[k] = v
+	}
+	return funcs
+}
+
+func (r *Gin) readTemplate(name string) ([]byte, error) {
+	var bytes []byte
+	//调试模式下尽可能加载硬盘文件
+	if r.config.Debug {
+		bytes, _ = ioutil.ReadFile(path.Join(r.config.TemplatePathBase, name))
+	}
+	if bytes != nil {
+		return bytes, nil
+	}
+	return r.config.TemplateEmbed.ReadFile(path.Join(r.config.TemplatePathBase, name))
+}
+
 func (r *Gin) getAllTemplateFiles(path string) ([]string, error) {
 	var (
 		err   error
@@ -0,0 +1,330 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ginCompressMinSize 小于该大小的文件不做实时压缩
+const ginCompressMinSize = 1024
+
+// ginFingerprintRegexp 匹配带内容指纹的文件名, 如 app.3f2a9c1b.js
+var ginFingerprintRegexp = regexp.MustCompile(`[.-][0-9a-fA-F]{8,}\.[^./]+$`)
+
+// ginManifestFingerprintRegexp 匹配 ginFingerprintName 生成的指纹
+var ginManifestFingerprintRegexp = regexp.MustCompile(`\.([0-9a-f]{8})(?:\.[^./]*)?$`)
+
+// ginAsset 静态文件及其校验信息, 嵌入文件只计算一次, 调试模式下的硬盘文件在修改时间或大小变化时重新计算
+type ginAsset struct {
+	name    string
+	hash    string
+	etag    string
+	modTime time.Time
+	size    int64
+	data    []byte
+
+	//实时压缩的结果, 随文件内容一起失效
+	gzipOnce sync.Once
+	gzipData []byte
+	gzipErr  error
+}
+
+func (a *ginAsset) gzipped() ([]byte, error) {
+	a.gzipOnce.Do(func() {
+		var buf bytes.Buffer
+		w, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+		if _, a.gzipErr = w.Write(a.data); a.gzipErr != nil {
+			return
+		}
+		if a.gzipErr = w.Close(); a.gzipErr == nil {
+			a.gzipData = buf.Bytes()
+		}
+	})
+	return a.gzipData, a.gzipErr
+}
+
+type ginAssets struct {
+	mux   sync.RWMutex
+	items map[string]*ginAsset
+	start time.Time
+
+	//启动时生成, 文件名 => 带指纹的文件名, 以及反向映射
+	manifest     map[string]string
+	fingerprints map[string]string
+}
+
+func newGinAssets() *ginAssets {
+	return &ginAssets{
+		items:        make(map[string]*ginAsset),
+		start:        time.Now(),
+		manifest:     make(map[string]string),
+		fingerprints: make(map[string]string),
+	}
+}
+
+// buildAssetManifest 为 StaticEmbed 中的每个文件生成带内容指纹的文件名, 预压缩的 .br/.gz 文件随原文件提供
+func (r *Gin) buildAssetManifest() error {
+	root := r.config.StaticPathBase
+	if root == "" {
+		root = "."
+	}
+	return fs.WalkDir(r.config.StaticEmbed, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			//未嵌入静态文件
+			if name == root && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, root), "/")
+		if ext := path.Ext(name); ext == ".br" || ext == ".gz" {
+			if _, err = fs.Stat(r.config.StaticEmbed, path.Join(root, strings.TrimSuffix(name, ext))); err == nil {
+				return nil
+			}
+		}
+		asset, err := r.asset(name)
+		if err != nil {
+			return err
+		}
+		fingerprint := ginFingerprintName(name, asset.hash)
+		r.assets.manifest[name] = fingerprint
+		r.assets.fingerprints[fingerprint] = name
+		return nil
+	})
+}
+
+// ginFingerprintName js/app.js => js/app.3f2a9c1b.js
+func ginFingerprintName(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash[:8] + ext
+}
+
+// AssetURL 返回带内容指纹的访问地址, 模板中通过 {{ asset "js/app.js" }} 使用; 文件不存在时返回原地址
+func (r *Gin) AssetURL(name string) string {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	fingerprint, ok := r.assets.manifest[name]
+	//调试模式下硬盘文件可能已修改, 按当前内容计算
+	if r.config.Debug || !ok {
+		if asset, err := r.asset(name); err == nil {
+			fingerprint = ginFingerprintName(name, asset.hash)
+		} else {
+			fingerprint = name
+		}
+	}
+	if r.config.StaticUrlBase == "" {
+		return "/" + fingerprint
+	}
+	return "/" + r.config.StaticUrlBase + "/" + fingerprint
+}
+
+// AssetManifest 返回文件名到带指纹文件名的映射
+func (r *Gin) AssetManifest() map[string]string {
+	manifest := make(map[string]string, len(r.assets.manifest))
+	for k, v := range r.assets.manifest {
+		manifest[k] = v
+	}
+	return manifest
+}
+
+// resolveFingerprint 将带指纹的文件名还原, ok 为 true 时内容与指纹一致, 可按 immutable 缓存
+func (r *Gin) resolveFingerprint(name string) (string, bool) {
+	if original, ok := r.assets.fingerprints[name]; ok && !r.config.Debug {
+		return original, true
+	}
+	loc := ginManifestFingerprintRegexp.FindStringSubmatchIndex(name)
+	if loc == nil {
+		return name, false
+	}
+	original := name[:loc[0]] + name[loc[3]:]
+	asset, err := r.asset(original)
+	if err != nil || !strings.HasPrefix(asset.hash, name[loc[2]:loc[3]]) {
+		return name, false
+	}
+	return original, !r.config.Debug
+}
+
+// asset 读取静态文件, 目录时读取其中的 index.html
+func (r *Gin) asset(name string) (*ginAsset, error) {
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "index.html"
+	}
+
+	file, err := r.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		if strings.HasSuffix(name, "/index.html") || name == "index.html" {
+			return nil, fs.ErrNotExist
+		}
+		return r.asset(name + "/index.html")
+	}
+
+	r.assets.mux.RLock()
+	cached, ok := r.assets.items[name]
+	r.assets.mux.RUnlock()
+	if ok && cached.size == stat.Size() && cached.modTime.Equal(r.assetModTime(stat)) {
+		return cached, nil
+	}
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	asset := &ginAsset{
+		name:    name,
+		hash:    hash,
+		etag:    `"` + hash[:32] + `"`,
+		modTime: r.assetModTime(stat),
+		size:    stat.Size(),
+		data:    data,
+	}
+
+	r.assets.mux.Lock()
+	r.assets.items[name] = asset
+	r.assets.mux.Unlock()
+	return asset, nil
+}
+
+// assetModTime 嵌入文件没有修改时间, 以进程启动时间代替
+func (r *Gin) assetModTime(stat fs.FileInfo) time.Time {
+	if stat.ModTime().IsZero() {
+		return r.assets.start
+	}
+	return stat.ModTime()
+}
+
+// serveStatic 由 http.ServeContent 处理 If-None-Match/If-Modified-Since 及 Range
+func (r *Gin) serveStatic(c *gin.Context) {
+	name, immutable := r.resolveFingerprint(strings.TrimPrefix(c.Param("filepath"), "/"))
+	asset, err := r.asset(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrInvalid) {
+			c.AbortWithStatus(http.StatusNotFound)
+		} else {
+			_ = c.AbortWithError(http.StatusInternalServerError, err)
+		}
+		return
+	}
+
+	r.setStaticCacheHeaders(c, name, immutable)
+	//压缩后的内容无法探测类型, 按原文件确定
+	ctype := mime.TypeByExtension(path.Ext(asset.name))
+	if ctype == "" {
+		ctype = http.DetectContentType(asset.data)
+	}
+	c.Header("Content-Type", ctype)
+
+	etag, data := asset.etag, asset.data
+	if encoding, encoded := r.encodeAsset(c, asset); encoding != "" {
+		c.Header("Content-Encoding", encoding)
+		etag, data = `"`+asset.hash[:32]+"-"+encoding+`"`, encoded
+	}
+	c.Header("ETag", etag)
+	http.ServeContent(c.Writer, c.Request, asset.name, asset.modTime, bytes.NewReader(data))
+}
+
+// encodeAsset 优先使用同目录下预压缩的 .br/.gz 文件, 否则对文本类文件实时 gzip 压缩并缓存
+func (r *Gin) encodeAsset(c *gin.Context, asset *ginAsset) (string, []byte) {
+	var (
+		accept       = c.Request.Header.Get("Accept-Encoding")
+		compressible = ginCompressible(asset.name)
+		varied       = compressible
+	)
+	defer func() {
+		if varied {
+			c.Writer.Header().Add("Vary", "Accept-Encoding")
+		}
+	}()
+
+	for _, v := range []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		sibling, err := r.asset(asset.name + v.ext)
+		if err != nil {
+			continue
+		}
+		varied = true
+		if ginAcceptsEncoding(accept, v.encoding) {
+			return v.encoding, sibling.data
+		}
+	}
+
+	if compressible && asset.size >= ginCompressMinSize && ginAcceptsEncoding(accept, "gzip") {
+		if data, err := asset.gzipped(); err == nil && len(data) < len(asset.data) {
+			return "gzip", data
+		}
+	}
+	return "", nil
+}
+
+func ginCompressible(name string) bool {
+	ctype, _, _ := strings.Cut(mime.TypeByExtension(path.Ext(name)), ";")
+	if strings.HasPrefix(ctype, "text/") {
+		return true
+	}
+	switch ctype {
+	case "application/javascript", "application/json", "application/xml", "application/wasm", "image/svg+xml", "application/manifest+json":
+		return true
+	}
+	return strings.HasSuffix(ctype, "+json") || strings.HasSuffix(ctype, "+xml")
+}
+
+// ginAcceptsEncoding 解析 Accept-Encoding, q=0 视为不接受
+func ginAcceptsEncoding(accept, encoding string) bool {
+	for _, v := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(v), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != encoding && name != "*" {
+			continue
+		}
+		params = strings.ReplaceAll(strings.ToLower(params), " ", "")
+		if q, ok := strings.CutPrefix(params, "q="); ok {
+			if f, err := strconv.ParseFloat(q, 64); err == nil && f <= 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// setStaticCacheHeaders 带指纹的文件名内容不会变化, 按 immutable 长期缓存
+func (r *Gin) setStaticCacheHeaders(c *gin.Context, name string, immutable bool) {
+	if immutable || r.config.StaticImmutable && ginFingerprintRegexp.MatchString(name) {
+		c.Header("Expires", time.Now().UTC().AddDate(1, 0, 0).Format(http.TimeFormat))
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		return
+	}
+	if r.config.CacheMinute > 0 {
+		c.Header("Expires", time.Now().UTC().Add(time.Minute*time.Duration(r.config.CacheMinute)).Format(http.TimeFormat))
+		c.Header("Cache-Control", "max-age="+strconv.Itoa(r.config.CacheMinute*60)+", must-revalidate")
+	} else {
+		c.Header("Cache-Control", "no-cache")
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ginTemplates 局部模板、布局及其他页面的主体组成共享模板集, 每个页面使用共享模板集的副本并解析页面自身,
+// 页面中 {{define}} 的块只覆盖本页面使用的布局; 调试与发布模式使用相同的规则
+type ginTemplates struct {
+	mux   sync.Mutex
+	base  *template.Template
+	pages map[string]*template.Template
+}
+
+func newGinTemplates() *ginTemplates {
+	return &ginTemplates{pages: make(map[string]*template.Template)}
+}
+
+// pageTemplate 缓存共享模板集及页面模板, 调试模式下由文件监听在模板修改后清空缓存
+func (r *Gin) pageTemplate(name, layout string) (*template.Template, error) {
+	r.templates.mux.Lock()
+	defer r.templates.mux.Unlock()
+
+	key := layout + "|" + name
+	if tmpl, ok := r.templates.pages[key]; ok {
+		return tmpl, nil
+	}
+
+	if r.templates.base == nil {
+		base, err := r.loadPathTemplates(r.config.TemplatePathBase)
+		if err != nil {
+			return nil, err
+		}
+		r.templates.base, r.templates.pages = base, make(map[string]*template.Template)
+	}
+	if r.templates.base.Lookup(name) == nil {
+		return nil, fmt.Errorf("template %s not found", name)
+	}
+	if layout != "" && r.templates.base.Lookup(layout) == nil {
+		return nil, fmt.Errorf("layout %s of template %s not found", layout, name)
+	}
+
+	tmpl, err := r.templates.base.Clone()
+	if err != nil {
+		return nil, err
+	}
+	bytes, err := r.readTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	if tmpl, err = tmpl.New(name).Parse(string(bytes)); err != nil {
+		return nil, err
+	}
+	r.templates.pages[key] = tmpl
+	return tmpl, nil
+}
+
+func (r *Gin) resetTemplates() {
+	r.templates.mux.Lock()
+	defer r.templates.mux.Unlock()
+	r.templates.base, r.templates.pages = nil, make(map[string]*template.Template)
+}
+
+// layoutFor 按 TemplateLayouts 的最长目录前缀或 TemplateLayout 确定页面布局, 布局及局部模板本身不使用布局
+func (r *Gin) layoutFor(name string) string {
+	if ginIsPartial(name) || r.isLayout(name) {
+		return ""
+	}
+
+	layout, matched := r.config.TemplateLayout, -1
+	for dir, v := range r.config.TemplateLayouts {
+		dir = strings.Trim(dir, "/")
+		if dir == "." {
+			dir = ""
+		}
+		if (dir == "" || strings.HasPrefix(name, dir+"/")) && len(dir) > matched {
+			layout, matched = v, len(dir)
+		}
+	}
+	if layout == "-" {
+		return ""
+	}
+	return layout
+}
+
+// ginDefaultTemplateExts 调试模式下从硬盘加载的模板扩展名, 嵌入的模板文件的扩展名同样视为模板
+var ginDefaultTemplateExts = []string{".html", ".htm", ".tmpl", ".tpl", ".gohtml"}
+
+// ginTemplateExts 合并默认扩展名与 files 中出现的扩展名
+func ginTemplateExts(files []string) map[string]bool {
+	exts := make(map[string]bool, len(ginDefaultTemplateExts))
+	for _, v := range ginDefaultTemplateExts {
+		exts[v] = true
+	}
+	for _, v := range files {
+		if ext := path.Ext(v); ext != "" {
+			exts[ext] = true
+		}
+	}
+	return exts
+}
+
+// ginIsTemplateFile 忽略隐藏文件(如 .DS_Store、编辑器的 .*.swp)及非模板扩展名的文件
+func ginIsTemplateFile(name string, exts map[string]bool) bool {
+	base := path.Base(name)
+	return !strings.HasPrefix(base, ".") && exts[path.Ext(base)]
+}
+
+// appendDiskTemplateFiles 合并硬盘上模板目录中的模板文件, 未设置 TemplatePathBase 时不读取硬盘, 避免遍历工作目录
+func (r *Gin) appendDiskTemplateFiles(files []string) []string {
+	root := r.config.TemplatePathBase
+	if root == "" {
+		return files
+	}
+	exts := ginTemplateExts(files)
+	exists := make(map[string]bool, len(files))
+	for _, v := range files {
+		exists[v] = true
+	}
+	_ = filepath.WalkDir(root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if name != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, name)
+		if err != nil {
+			return nil
+		}
+		if rel = filepath.ToSlash(rel); !exists[rel] && ginIsTemplateFile(rel, exts) {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	sort.Strings(files)
+	return files
+}
+
+func (r *Gin) isLayout(name string) bool {
+	if name == r.config.TemplateLayout {
+		return true
+	}
+	for _, v := range r.config.TemplateLayouts {
+		if name == v {
+			return true
+		}
+	}
+	return false
+}
+
+func ginIsPartial(name string) bool {
+	return strings.HasPrefix(path.Base(name), "_")
+}
+
+func ginPartialName(name string) string {
+	base := strings.TrimPrefix(path.Base(name), "_")
+	return strings.TrimSuffix(base, path.Ext(base))
+}
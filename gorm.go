@@ -1,17 +1,43 @@
 package utils
 
 import (
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
+	"encoding/json"
 	"fmt"
 	"github.com/Eun/go-convert"
-	"github.com/iancoleman/strcase"
+	"gorm.io/gorm/schema"
+	"math"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	gormValuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	gormScannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	gormTimeType    = reflect.TypeOf(time.Time{})
+)
+
+var gormTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", time.DateTime, time.DateOnly}
+
+// gormField 单个字段的标签解析结果, embedded 为 true 时字段为需要展开的嵌入结构体
+type gormField struct {
+	column     string
+	embedded   bool
+	prefix     string
+	serializer string
+}
+
+// GormEntityToMap 按 gorm 默认的 NamingStrategy 解析列名, 自定义了命名策略时使用 GormEntityToMapWithNamer
 func GormEntityToMap(entity any) (map[string]any, error) {
+	return GormEntityToMapWithNamer(entity, schema.NamingStrategy{})
+}
+
+// GormEntityToMapWithNamer 按 namer 解析列名, 传入 db.NamingStrategy 时列名与数据库一致
+func GormEntityToMapWithNamer(entity any, namer schema.Namer) (map[string]any, error) {
 	rValue := reflect.ValueOf(entity).Elem()
 
 	for rValue.Kind() == reflect.Ptr {
@@ -25,182 +51,490 @@ func GormEntityToMap(entity any) (map[string]any, error) {
 		return nil, fmt.Errorf("invalid entity param")
 	}
 
-	m := make(map[string]any)
+	fields := gormFields(rValue.Type(), namer)
+	m := make(map[string]any, len(fields))
+	for _, field := range fields {
+		vField, ok := gormFieldByIndex(rValue, field.index, false)
+		if !ok {
+			//嵌入的结构体指针为 nil 时各列均为 NULL
+			m[field.column] = nil
+			continue
+		}
+		value, ok, err := gormFieldValue(vField, field.serializer)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", field.column, err)
+		}
+		if ok {
+			m[field.column] = value
+		}
+	}
+	return m, nil
+}
 
-	rType := rValue.Type()
-	for i := 0; i < rValue.NumField(); i++ {
-		vField := rValue.Field(i)
-		tField := rType.Field(i)
+func GormMapToEntity(m map[string]any, entity any) error {
+	return GormMapToEntityWithNamer(m, entity, schema.NamingStrategy{})
+}
 
-		tag := strcase.ToSnake(tField.Name)
+func GormMapToEntityWithNamer(m map[string]any, entity any, namer schema.Namer) error {
+	rValue := reflect.ValueOf(entity).Elem()
 
-		tags := strings.Split(tField.Tag.Get("gorm"), ";")
-		for _, v := range tags {
-			tagInfo := strings.Split(v, ":")
-			if len(tagInfo) == 2 && strings.TrimSpace(tagInfo[0]) == "column" {
-				if strings.TrimSpace(tagInfo[1]) != "" {
-					tag = strings.TrimSpace(tagInfo[1])
-				}
-				break
-			}
+	for rValue.Kind() == reflect.Ptr {
+		if rValue.IsNil() {
+			rValue.Set(reflect.New(rValue.Type().Elem()))
 		}
+		rValue = rValue.Elem()
+	}
+
+	if rValue.Kind() != reflect.Struct {
+		return fmt.Errorf("invalid entity param")
+	}
 
-		switch vField.Kind() {
-		case reflect.Bool:
-			fallthrough
-		case reflect.Int:
-			fallthrough
-		case reflect.Int32:
-			fallthrough
-		case reflect.Int64:
-			fallthrough
-		case reflect.Float32:
-			fallthrough
-		case reflect.Float64:
-			fallthrough
-		case reflect.String:
-			m[tag] = vField.Interface()
-
-		case reflect.Struct:
-			if reflect.PtrTo(vField.Type()).Implements(reflect.TypeOf((*IRepositoryModel)(nil)).Elem()) {
-				continue
+	for _, field := range gormFields(rValue.Type(), namer) {
+		valUnknown, ok := m[field.column]
+		if !ok {
+			continue
+		}
+		vField, _ := gormFieldByIndex(rValue, field.index, true)
+		if err := gormSetField(vField, valUnknown, field.serializer); err != nil {
+			return fmt.Errorf("column %s: %w", field.column, err)
+		}
+	}
+	return nil
+}
+
+// gormFieldMeta 字段到列的映射, index 为从实体到字段的路径, 嵌入结构体已展开
+type gormFieldMeta struct {
+	index      []int
+	column     string
+	serializer string
+}
+
+var (
+	gormFieldsCache  sync.Map
+	gormSchemaCaches sync.Map
+)
+
+type gormFieldsKey struct {
+	rType reflect.Type
+	namer schema.Namer
+}
+
+// gormFields 按类型及命名策略缓存字段映射, 优先使用 gorm 解析的 schema, 解析失败时(如关联未定义外键)按标签自行解析
+// 命名策略不可比较时不缓存
+func gormFields(rType reflect.Type, namer schema.Namer) []gormFieldMeta {
+	cacheable := reflect.TypeOf(namer).Comparable()
+	key := gormFieldsKey{rType: rType, namer: namer}
+	if cacheable {
+		if v, ok := gormFieldsCache.Load(key); ok {
+			return v.([]gormFieldMeta)
+		}
+	}
+
+	schemaCache := &sync.Map{}
+	if cacheable {
+		v, _ := gormSchemaCaches.LoadOrStore(namer, schemaCache)
+		schemaCache = v.(*sync.Map)
+	}
+	fields, err := gormSchemaFields(rType, namer, schemaCache)
+	if err != nil {
+		fields = gormWalkFields(rType, namer, nil, "")
+	}
+	if !cacheable {
+		return fields
+	}
+	v, _ := gormFieldsCache.LoadOrStore(key, fields)
+	return v.([]gormFieldMeta)
+}
+
+func gormSchemaFields(rType reflect.Type, namer schema.Namer, schemaCache *sync.Map) ([]gormFieldMeta, error) {
+	sch, err := schema.Parse(reflect.New(rType).Interface(), schemaCache, namer)
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]gormFieldMeta, 0, len(sch.DBNames))
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		fields = append(fields, gormFieldMeta{
+			index:      field.StructField.Index,
+			column:     field.DBName,
+			serializer: strings.ToLower(strings.TrimSpace(field.TagSettings["SERIALIZER"])),
+		})
+	}
+	return fields, nil
+}
+
+func gormWalkFields(rType reflect.Type, namer schema.Namer, index []int, prefix string) []gormFieldMeta {
+	fields := make([]gormFieldMeta, 0, rType.NumField())
+	for i := 0; i < rType.NumField(); i++ {
+		field, ok := gormParseField(rType.Field(i), namer, prefix)
+		if !ok {
+			continue
+		}
+		fieldIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+		if field.embedded {
+			fType := rType.Field(i).Type
+			for fType.Kind() == reflect.Ptr {
+				fType = fType.Elem()
 			}
-			if reflect.PtrTo(vField.Type()).Implements(reflect.TypeOf((*driver.Valuer)(nil)).Elem()) {
-				scanResult := vField.Addr().MethodByName("Value").Call([]reflect.Value{})
-				if len(scanResult) != 2 {
-					return nil, fmt.Errorf("fail to get value")
-				}
-				if !scanResult[1].IsNil() {
-					return nil, scanResult[1].Interface().(error)
+			fields = append(fields, gormWalkFields(fType, namer, fieldIndex, field.prefix)...)
+			continue
+		}
+		fields = append(fields, gormFieldMeta{index: fieldIndex, column: field.column, serializer: field.serializer})
+	}
+	return fields
+}
+
+// gormFieldByIndex 经过嵌入的结构体指针取字段, 指针为 nil 且 alloc 为 false 时 ok 为 false
+// gorm schema 以负数 -i-1 表示第 i 个字段为嵌入的结构体指针, 与 gormWalkFields 的非负下标均可处理
+func gormFieldByIndex(rValue reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if x < 0 {
+			x = -x - 1
+		}
+		if i > 0 {
+			for rValue.Kind() == reflect.Ptr {
+				if rValue.IsNil() {
+					if !alloc {
+						return reflect.Value{}, false
+					}
+					rValue.Set(reflect.New(rValue.Type().Elem()))
 				}
-				m[tag] = scanResult[0].Interface()
-			} else {
-				return nil, fmt.Errorf("unsupported type: %s", vField.Type())
+				rValue = rValue.Elem()
 			}
 		}
+		rValue = rValue.Field(x)
 	}
+	return rValue, true
+}
 
-	return m, nil
+// gormParseField 按 gorm 的规则解析列名, 忽略未导出字段与 `gorm:"-"` 字段
+func gormParseField(tField reflect.StructField, namer schema.Namer, prefix string) (gormField, bool) {
+	if !tField.IsExported() {
+		return gormField{}, false
+	}
+	tags := schema.ParseTagSetting(tField.Tag.Get("gorm"), ";")
+	if v, ok := tags["-"]; ok && (v == "-" || strings.EqualFold(strings.TrimSpace(v), "all")) {
+		return gormField{}, false
+	}
+
+	fType := tField.Type
+	for fType.Kind() == reflect.Ptr {
+		fType = fType.Elem()
+	}
+	if _, ok := tags["EMBEDDED"]; (ok || tField.Anonymous) && fType.Kind() == reflect.Struct && !gormIsScalarStruct(fType) {
+		return gormField{embedded: true, prefix: prefix + tags["EMBEDDEDPREFIX"]}, true
+	}
+
+	column := namer.ColumnName("", tField.Name)
+	if v := strings.TrimSpace(tags["COLUMN"]); v != "" {
+		column = v
+	}
+	return gormField{column: prefix + column, serializer: strings.ToLower(strings.TrimSpace(tags["SERIALIZER"]))}, true
 }
 
-func GormMapToEntity(m map[string]any, entity any) error {
-	rValue := reflect.ValueOf(entity).Elem()
-	rType := reflect.TypeOf(entity).Elem()
+// gormIsScalarStruct time.Time 及实现了 Valuer/Scanner 的结构体按单列处理, 其余结构体视为关联
+func gormIsScalarStruct(t reflect.Type) bool {
+	return t == gormTimeType ||
+		t.Implements(gormValuerType) ||
+		reflect.PtrTo(t).Implements(gormValuerType) ||
+		reflect.PtrTo(t).Implements(gormScannerType)
+}
 
-	for i := 0; i < rValue.NumField(); i++ {
-		vField := rValue.Field(i)
-		tField := rType.Field(i)
+// gormIsColumnType 是否能映射为单列
+func gormIsColumnType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.Uint8
+	case reflect.Struct:
+		return gormIsScalarStruct(t)
+	default:
+		return false
+	}
+}
 
-		tag := strcase.ToSnake(tField.Name)
+func gormFieldValue(vField reflect.Value, serializer string) (any, bool, error) {
+	if serializer == "json" {
+		if vField.Kind() == reflect.Ptr && vField.IsNil() {
+			return nil, true, nil
+		}
+		bs, err := json.Marshal(vField.Interface())
+		if err != nil {
+			return nil, false, err
+		}
+		return string(bs), true, nil
+	}
 
-		tags := strings.Split(tField.Tag.Get("gorm"), ";")
-		for _, v := range tags {
-			tagInfo := strings.Split(v, ":")
-			if len(tagInfo) == 2 && strings.TrimSpace(tagInfo[0]) == "column" {
-				if strings.TrimSpace(tagInfo[1]) != "" {
-					tag = strings.TrimSpace(tagInfo[1])
-				}
-				break
-			}
+	if vField.Kind() == reflect.Ptr {
+		if !gormIsColumnType(vField.Type().Elem()) {
+			return nil, false, nil
+		}
+		if vField.IsNil() {
+			return nil, true, nil
 		}
+		vField = vField.Elem()
+	}
 
-		valUnknown, ok := m[tag]
-		if !ok {
-			continue
+	if valuer, ok := gormValuer(vField); ok {
+		value, err := valuer.Value()
+		if err != nil {
+			return nil, false, err
 		}
+		return value, true, nil
+	}
+
+	if !gormIsColumnType(vField.Type()) {
+		return nil, false, nil
+	}
+	return vField.Interface(), true, nil
+}
+
+func gormValuer(vField reflect.Value) (driver.Valuer, bool) {
+	if vField.Type().Implements(gormValuerType) {
+		return vField.Interface().(driver.Valuer), true
+	}
+	if vField.CanAddr() && vField.Addr().Type().Implements(gormValuerType) {
+		return vField.Addr().Interface().(driver.Valuer), true
+	}
+	return nil, false
+}
+
+func gormSetField(vField reflect.Value, valUnknown any, serializer string) error {
+	if vField.Kind() == reflect.Ptr && valUnknown == nil {
+		vField.Set(reflect.Zero(vField.Type()))
+		return nil
+	}
+	for vField.Kind() == reflect.Ptr {
+		if vField.IsNil() {
+			vField.Set(reflect.New(vField.Type().Elem()))
+		}
+		vField = vField.Elem()
+	}
+
+	if serializer == "json" {
+		var bs []byte
+		switch v := valUnknown.(type) {
+		case nil:
+			vField.Set(reflect.Zero(vField.Type()))
+			return nil
+		case []byte:
+			bs = v
+		case string:
+			bs = []byte(v)
+		default:
+			return fmt.Errorf("unsupported json value: %T", valUnknown)
+		}
+		return json.Unmarshal(bs, vField.Addr().Interface())
+	}
 
-		for vField.Kind() == reflect.Ptr {
-			if vField.IsNil() {
-				vField.Set(reflect.New(vField.Type().Elem()))
+	if vField.Kind() == reflect.Struct && vField.Type() != gormTimeType {
+		if !reflect.PtrTo(vField.Type()).Implements(gormScannerType) {
+			//关联结构体不映射列
+			return nil
+		}
+		if valUnknown == nil {
+			vField.Set(reflect.Zero(vField.Type()))
+			return nil
+		}
+		if vField.Type().String() == "gorm.DeletedAt" {
+			t, err := gormParseTime(valUnknown)
+			if err != nil {
+				return err
 			}
-			vField = vField.Elem()
+			valUnknown = t
 		}
+		return vField.Addr().Interface().(sql.Scanner).Scan(valUnknown)
+	}
 
-		switch vField.Kind() {
-		case reflect.Bool:
-			var boolResult bool
+	switch vField.Kind() {
+	case reflect.Bool:
+		boolResult, ok := valUnknown.(bool)
+		if !ok {
 			if err := convert.Convert(valUnknown, &boolResult); err != nil {
 				return err
 			}
-			vField.SetBool(boolResult)
-		case reflect.Int:
-			fallthrough
-		case reflect.Int32:
-			fallthrough
-		case reflect.Int64:
-			var intResult int64
+		}
+		vField.SetBool(boolResult)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intResult, ok := gormFastInt(valUnknown)
+		if !ok {
 			if err := convert.Convert(valUnknown, &intResult); err != nil {
 				return err
 			}
-			vField.SetInt(intResult)
-		case reflect.Float32:
-			fallthrough
-		case reflect.Float64:
-			var floatResult float64
-			if err := convert.Convert(valUnknown, &floatResult); err != nil {
+		}
+		if vField.OverflowInt(intResult) {
+			return fmt.Errorf("value %d overflows %s", intResult, vField.Type())
+		}
+		vField.SetInt(intResult)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintResult, ok := gormFastUint(valUnknown)
+		if !ok {
+			if err := convert.Convert(valUnknown, &uintResult); err != nil {
 				return err
 			}
-			vField.SetFloat(floatResult)
-		case reflect.String:
-			var stringResult string
-			switch valUnknown.(type) {
-			case []byte:
-				stringResult = string(valUnknown.([]byte))
-			case string:
-				stringResult = valUnknown.(string)
-			default:
-				if err := convert.Convert(valUnknown, &stringResult); err != nil {
-					return err
-				}
-			}
-			vField.SetString(stringResult)
-
-		case reflect.Struct:
-			if reflect.PtrTo(vField.Type()).Implements(reflect.TypeOf((*sql.Scanner)(nil)).Elem()) {
-				var scanResult []reflect.Value
-				if vField.Type().String() == "gorm.DeletedAt" {
-					if valUnknown == nil {
-						vField.Set(reflect.Zero(vField.Type()))
-						continue
-					}
-					var t time.Time
-					var err error
-					switch valUnknown.(type) {
-					case time.Time:
-						t = valUnknown.(time.Time)
-					case string:
-						if t, err = time.ParseInLocation(time.DateTime, valUnknown.(string), time.Local); err != nil {
-							return err
-						}
-					default:
-						return fmt.Errorf("unsupported type: %s", vField.Type())
-					}
-					scanResult = vField.Addr().MethodByName("Scan").Call([]reflect.Value{reflect.ValueOf(t)})
-					if len(scanResult) == 0 {
-						return fmt.Errorf("fail to transfer")
-					}
-					if !scanResult[0].IsNil() {
-						return scanResult[0].Interface().(error)
-					}
-				} else {
-					if valUnknown == nil {
-						vField.Set(reflect.Zero(vField.Type()))
-						continue
-					}
-					scanResult = vField.Addr().MethodByName("Scan").Call([]reflect.Value{reflect.ValueOf(valUnknown)})
-					if len(scanResult) == 0 {
-						return fmt.Errorf("fail to transfer")
-					}
-					if !scanResult[0].IsNil() {
-						return scanResult[0].Interface().(error)
-					}
-				}
-			} else {
-				return fmt.Errorf("unsupported type: %s", vField.Type())
+		}
+		if vField.OverflowUint(uintResult) {
+			return fmt.Errorf("value %d overflows %s", uintResult, vField.Type())
+		}
+		vField.SetUint(uintResult)
+	case reflect.Float32, reflect.Float64:
+		floatResult, ok := gormFastFloat(valUnknown)
+		if !ok {
+			if err := convert.Convert(valUnknown, &floatResult); err != nil {
+				return err
 			}
+		}
+		vField.SetFloat(floatResult)
+	case reflect.String:
+		var stringResult string
+		switch v := valUnknown.(type) {
+		case []byte:
+			stringResult = string(v)
+		case string:
+			stringResult = v
 		default:
+			if err := convert.Convert(valUnknown, &stringResult); err != nil {
+				return err
+			}
+		}
+		vField.SetString(stringResult)
+	case reflect.Slice:
+		if vField.Type().Elem().Kind() != reflect.Uint8 {
 			return fmt.Errorf("unsupported type: %s", vField.Type())
 		}
+		var bytesResult []byte
+		switch v := valUnknown.(type) {
+		case nil:
+		case []byte:
+			bytesResult = append([]byte(nil), v...)
+		case string:
+			bytesResult = []byte(v)
+		default:
+			return fmt.Errorf("unsupported value %T for %s", valUnknown, vField.Type())
+		}
+		vField.SetBytes(bytesResult)
+	case reflect.Struct:
+		if valUnknown == nil {
+			vField.Set(reflect.Zero(vField.Type()))
+			return nil
+		}
+		t, err := gormParseTime(valUnknown)
+		if err != nil {
+			return err
+		}
+		vField.Set(reflect.ValueOf(t))
+	default:
+		return fmt.Errorf("unsupported type: %s", vField.Type())
 	}
 	return nil
 }
+
+// gormFastInt 数值类型直接转换, 避免 convert.Convert 的开销
+func gormFastInt(valUnknown any) (int64, bool) {
+	rValue := reflect.ValueOf(valUnknown)
+	switch rValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rValue.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v := rValue.Uint(); v <= math.MaxInt64 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+func gormFastUint(valUnknown any) (uint64, bool) {
+	rValue := reflect.ValueOf(valUnknown)
+	switch rValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v := rValue.Int(); v >= 0 {
+			return uint64(v), true
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rValue.Uint(), true
+	}
+	return 0, false
+}
+
+func gormFastFloat(valUnknown any) (float64, bool) {
+	rValue := reflect.ValueOf(valUnknown)
+	switch rValue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rValue.Float(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rValue.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rValue.Uint()), true
+	}
+	return 0, false
+}
+
+func gormParseTime(valUnknown any) (time.Time, error) {
+	var s string
+	switch v := valUnknown.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v == nil {
+			return time.Time{}, nil
+		}
+		return *v, nil
+	case []byte:
+		s = string(v)
+	case string:
+		s = v
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value: %T", valUnknown)
+	}
+	for _, layout := range gormTimeLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time value: %s", s)
+}
+
+// GormEntityDiff 比较同类型的两个实体, 返回 new 中取值不同的列, 可直接用于 Repository.Updates
+// 实现了 driver.Valuer 的字段按 Value() 的结果比较, ignores 为忽略的列名
+func GormEntityDiff(old, new any, ignores ...string) (map[string]any, error) {
+	if reflect.TypeOf(old) != reflect.TypeOf(new) {
+		return nil, fmt.Errorf("entity types mismatch: %T and %T", old, new)
+	}
+	oldMap, err := GormEntityToMap(old)
+	if err != nil {
+		return nil, err
+	}
+	newMap, err := GormEntityToMap(new)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range ignores {
+		delete(newMap, v)
+	}
+
+	diff := make(map[string]any)
+	for k, v := range newMap {
+		if !gormValueEqual(oldMap[k], v) {
+			diff[k] = v
+		}
+	}
+	return diff, nil
+}
+
+func gormValueEqual(a, b any) bool {
+	switch av := a.(type) {
+	case time.Time:
+		bv, ok := b.(time.Time)
+		return ok && av.Equal(bv)
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	}
+	return reflect.DeepEqual(a, b)
+}
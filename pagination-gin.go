@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaginationGinConfig struct {
+	PageParam    string
+	PerPageParam string
+	SortParam    string
+
+	DefaultPerPage int
+	//每页数量上限, 为 0 时默认 100, 小于 0 时不限制
+	MaxPerPage int
+
+	//允许的过滤参数 => 数据库列, 多个值(重复参数或逗号分隔)按 IN 条件处理
+	Filters map[string]string
+}
+
+type PaginationGinParams struct {
+	Page       int
+	PerPage    int
+	Sort       string
+	Conditions map[string]any
+}
+
+func BindPaginationGin(c *gin.Context, config PaginationGinConfig) (*PaginationGinParams, error) {
+	var (
+		err    error
+		query  = c.Request.URL.Query()
+		params = &PaginationGinParams{Page: 1, Conditions: make(map[string]any)}
+	)
+	config = config.withDefaults()
+	params.PerPage = config.DefaultPerPage
+
+	if v := query.Get(config.PageParam); v != "" {
+		if params.Page, err = strconv.Atoi(v); err != nil || params.Page < 1 {
+			return nil, fmt.Errorf("invalid %s param: %s", config.PageParam, v)
+		}
+	}
+	if v := query.Get(config.PerPageParam); v != "" {
+		if params.PerPage, err = strconv.Atoi(v); err != nil || params.PerPage < 1 {
+			return nil, fmt.Errorf("invalid %s param: %s", config.PerPageParam, v)
+		}
+	}
+	if config.MaxPerPage > 0 && params.PerPage > config.MaxPerPage {
+		params.PerPage = config.MaxPerPage
+	}
+	params.Sort = query.Get(config.SortParam)
+
+	for param, column := range config.Filters {
+		values := make([]string, 0)
+		for _, v := range query[param] {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					values = append(values, item)
+				}
+			}
+		}
+		switch len(values) {
+		case 0:
+		case 1:
+			params.Conditions[column] = values[0]
+		default:
+			params.Conditions[column] = values
+		}
+	}
+
+	return params, nil
+}
+
+func (config PaginationGinConfig) withDefaults() PaginationGinConfig {
+	if config.PageParam == "" {
+		config.PageParam = "page"
+	}
+	if config.PerPageParam == "" {
+		config.PerPageParam = "per_page"
+	}
+	if config.SortParam == "" {
+		config.SortParam = "sort"
+	}
+	if config.DefaultPerPage <= 0 {
+		config.DefaultPerPage = 15
+	}
+	if config.MaxPerPage == 0 {
+		config.MaxPerPage = 100
+	}
+	return config
+}
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetCondition(field string, value any) *Pagination[ModelType, PrimaryType, ResponseType] {
+	p.setCondition(field, value)
+	return p
+}
+
+// PaginateGin 从请求中读取分页/排序/过滤参数后分页查询, 请求过滤条件不会覆盖已设置的同名条件
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) PaginateGin(c *gin.Context, config PaginationGinConfig) (*PaginationResponse[ResponseType], error) {
+	params, err := BindPaginationGin(c, config)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range params.Conditions {
+		if _, ok := p.conditions[k]; !ok {
+			p.SetCondition(k, v)
+		}
+	}
+	if params.Sort != "" {
+		p.SetSortString(params.Sort)
+	}
+	return p.Paginate(params.Page, params.PerPage)
+}
+
+// ServeGin 分页查询并以 JSON 输出结果, 出错时不写入响应, 由调用方处理
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) ServeGin(c *gin.Context, config PaginationGinConfig) error {
+	resp, err := p.PaginateGin(c, config)
+	if err != nil {
+		return err
+	}
+	WritePaginationGin(c, resp, config)
+	return nil
+}
+
+func WritePaginationGin[ResponseType any](c *gin.Context, resp *PaginationResponse[ResponseType], config PaginationGinConfig) {
+	config = config.withDefaults()
+
+	lastPage := 1
+	if resp.PerPage > 0 && resp.Total > 0 {
+		lastPage = int((resp.Total + int64(resp.PerPage) - 1) / int64(resp.PerPage))
+	}
+
+	links := make([]string, 0, 4)
+	buildLink := func(page int, rel string) {
+		u := *c.Request.URL
+		query := u.Query()
+		query.Set(config.PageParam, strconv.Itoa(page))
+		query.Set(config.PerPageParam, strconv.Itoa(resp.PerPage))
+		u.RawQuery = query.Encode()
+		links = append(links, fmt.Sprintf("<%s>; rel=\"%s\"", paginationRequestURL(c, &u), rel))
+	}
+	buildLink(1, "first")
+	if resp.Page > 1 {
+		buildLink(resp.Page-1, "prev")
+	}
+	if resp.Page < lastPage {
+		buildLink(resp.Page+1, "next")
+	}
+	buildLink(lastPage, "last")
+
+	c.Header("Link", strings.Join(links, ", "))
+	c.Header("X-Total-Count", strconv.FormatInt(resp.Total, 10))
+	c.JSON(http.StatusOK, resp)
+}
+
+func paginationRequestURL(c *gin.Context, u *url.URL) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	//X-Forwarded-Proto 可由客户端任意设置, 只接受 http/https
+	switch proto := strings.ToLower(strings.TrimSpace(c.GetHeader("X-Forwarded-Proto"))); proto {
+	case "http", "https":
+		scheme = proto
+	}
+	u.Scheme, u.Host = scheme, c.Request.Host
+	return u.String()
+}
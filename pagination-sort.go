@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm/clause"
+)
+
+var paginationSortColumnRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type PaginationSort struct {
+	Column     string
+	Descending bool
+}
+
+// ParsePaginationSort 解析形如 "-created_at,name" 的排序参数, "-" 前缀表示降序, "+" 前缀或无前缀表示升序
+func ParsePaginationSort(s string) ([]PaginationSort, error) {
+	sorts := make([]PaginationSort, 0)
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		sort := PaginationSort{Column: v}
+		switch v[0] {
+		case '-':
+			sort.Column, sort.Descending = strings.TrimSpace(v[1:]), true
+		case '+':
+			sort.Column = strings.TrimSpace(v[1:])
+		}
+		if sort.Column == "" {
+			return nil, fmt.Errorf("invalid sort param: %s", v)
+		}
+		sorts = append(sorts, sort)
+	}
+	return sorts, nil
+}
+
+func (s PaginationSort) String() string {
+	if s.Descending {
+		return "-" + s.Column
+	}
+	return s.Column
+}
+
+func (s PaginationSort) orderByColumn(column string) clause.OrderByColumn {
+	var table string
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		table, column = column[:i], column[i+1:]
+	}
+	return clause.OrderByColumn{Column: clause.Column{Table: table, Name: column}, Desc: s.Descending}
+}
@@ -15,14 +15,18 @@ type PaginationResponse[ResponseType any] struct {
 
 type Pagination[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey, ResponseType any] struct {
 	response PaginationResponse[ModelType]
-	repo     *Repository[ModelType, PrimaryType]
+	repo     IRepository[ModelType, PrimaryType]
 
 	conditions map[string]any
-	scope      func(db *gorm.DB) *gorm.DB
+	//conditions 为 NewPagination/NewPaginationFor 传入的调用方 map 时为 false, 首次写入前复制
+	conditionsOwned bool
+	scope           func(db *gorm.DB) *gorm.DB
 
 	sortOnlyColumns []string
-	sort            string
-	descending      string
+	sortColumns     map[string]string
+	sorts           []PaginationSort
+	sortErr         error
+	filterErr       error
 	preloads        []string
 
 	presenter IPresenter[ModelType, ResponseType]
@@ -30,6 +34,13 @@ type Pagination[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey, R
 
 func NewPagination[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey, ResponseType any](
 	repo *Repository[ModelType, PrimaryType], conditions map[string]any, presenter IPresenter[ModelType, ResponseType],
+) *Pagination[ModelType, PrimaryType, ResponseType] {
+	return NewPaginationFor[ModelType, PrimaryType, ResponseType](repo, conditions, presenter)
+}
+
+// NewPaginationFor 接受任意 IRepository 实现, 如 RepositoryMemory
+func NewPaginationFor[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey, ResponseType any](
+	repo IRepository[ModelType, PrimaryType], conditions map[string]any, presenter IPresenter[ModelType, ResponseType],
 ) *Pagination[ModelType, PrimaryType, ResponseType] {
 	return &Pagination[ModelType, PrimaryType, ResponseType]{
 		repo:       repo,
@@ -57,12 +68,37 @@ func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetSort(column string
 }
 
 func (p *Pagination[ModelType, PrimaryType, ResponseType]) setSort(column, descending string) *Pagination[ModelType, PrimaryType, ResponseType] {
-	if strings.ToLower(descending) == "desc" {
-		p.descending = "desc"
+	p.sorts = p.sorts[:0]
+	p.sortErr = nil
+	return p.addSort(column, descending)
+}
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) AddSort(column string, descending bool) *Pagination[ModelType, PrimaryType, ResponseType] {
+	if descending {
+		return p.addSort(column, "desc")
 	} else {
-		p.descending = "asc"
+		return p.addSort(column, "asc")
 	}
-	p.sort = column
+}
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) addSort(column, descending string) *Pagination[ModelType, PrimaryType, ResponseType] {
+	if column != "" {
+		p.sorts = append(p.sorts, PaginationSort{Column: column, Descending: strings.ToLower(descending) == "desc"})
+	}
+	return p
+}
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetSorts(sorts ...PaginationSort) *Pagination[ModelType, PrimaryType, ResponseType] {
+	p.sorts = append(make([]PaginationSort, 0, len(sorts)), sorts...)
+	p.sortErr = nil
+	return p
+}
+
+// SetSortString 按 "-created_at,name" 格式设置多列排序, 解析错误在 Paginate 时返回
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetSortString(sort string) *Pagination[ModelType, PrimaryType, ResponseType] {
+	sorts, err := ParsePaginationSort(sort)
+	p.SetSorts(sorts...)
+	p.sortErr = err
 	return p
 }
 
@@ -71,51 +107,54 @@ func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetSortOnlyColumns(co
 	return p
 }
 
+// SetSortColumns 设置对外排序名到数据库列的映射, 同时作为排序白名单
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetSortColumns(columns map[string]string) *Pagination[ModelType, PrimaryType, ResponseType] {
+	p.sortColumns = columns
+	return p
+}
+
 func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetScope(scope func(db *gorm.DB) *gorm.DB) *Pagination[ModelType, PrimaryType, ResponseType] {
 	p.scope = scope
 	return p
 }
 
-func (p *Pagination[ModelType, PrimaryType, ResponseType]) Paginate(page, perPage int) (*PaginationResponse[ResponseType], error) {
-	var (
-		err        error
-		count      int64
-		m          ModelType
-		entities   []*ModelType
-		collection = make([]*ResponseType, 0)
-		builder    = p.repo.db
-	)
-
-	//构造条件
-	if p.scope != nil {
-		builder = p.scope(builder)
+// SetFilter 按 FilterConditions 的规则合并过滤结构体的条件, 解析错误在 Paginate 时返回
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) SetFilter(filter any) *Pagination[ModelType, PrimaryType, ResponseType] {
+	conditions, err := FilterConditions(filter)
+	if err != nil {
+		p.filterErr = err
+		return p
 	}
-	for k, v := range p.conditions {
-		builder = p.repo.buildWhereCondition(builder, k, v)
+	for k, v := range conditions {
+		p.setCondition(k, v)
 	}
+	return p
+}
 
-	//查询总数
-	if err = builder.Model(&m).Count(&count).Error; err != nil {
-		return nil, err
+// setCondition 首次写入时复制条件, 不修改调用方传入的 map
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) setCondition(field string, value any) {
+	if !p.conditionsOwned {
+		conditions := make(map[string]any, len(p.conditions)+1)
+		for k, v := range p.conditions {
+			conditions[k] = v
+		}
+		p.conditions, p.conditionsOwned = conditions, true
 	}
+	p.conditions[field] = value
+}
 
-	//preloads
-	if len(p.preloads) > 0 {
-		builder = p.repo.buildPreloads(builder, p.preloads...)
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) Paginate(page, perPage int) (*PaginationResponse[ResponseType], error) {
+	if p.sortErr != nil {
+		return nil, p.sortErr
+	}
+	if p.filterErr != nil {
+		return nil, p.filterErr
 	}
 
 	//排序
-	if p.sort != "" && p.descending != "" {
-		if len(p.sortOnlyColumns) > 0 {
-			for _, v := range p.sortOnlyColumns {
-				if v == p.sort {
-					builder = builder.Order(fmt.Sprintf("%s %s", p.sort, p.descending))
-					break
-				}
-			}
-		} else {
-			builder = builder.Order(fmt.Sprintf("%s %s", p.sort, p.descending))
-		}
+	sorts, err := p.resolveSorts()
+	if err != nil {
+		return nil, err
 	}
 
 	//分页设置
@@ -125,18 +164,57 @@ func (p *Pagination[ModelType, PrimaryType, ResponseType]) Paginate(page, perPag
 	if page < 1 {
 		page = 1
 	}
-	builder = builder.Limit(perPage).Offset(perPage * (page - 1))
-	if err = builder.Find(&entities).Error; err != nil {
+
+	entities, count, err := p.repo.FindPage(RepositoryPageQuery{
+		Conditions: p.conditions,
+		Scope:      p.scope,
+		Sorts:      sorts,
+		Preloads:   p.preloads,
+		Limit:      perPage,
+		Offset:     perPage * (page - 1),
+	})
+	if err != nil {
 		return nil, err
 	}
-
-	for _, v := range entities {
-		collection = append(collection, p.presenter.Present(v))
+	items, err := PresentMany(p.presenter, entities)
+	if err != nil {
+		return nil, err
 	}
+
 	return &PaginationResponse[ResponseType]{
 		Page:    page,
 		PerPage: perPage,
 		Total:   count,
-		Items:   collection,
+		Items:   items,
 	}, nil
 }
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) resolveSorts() ([]PaginationSort, error) {
+	sorts := make([]PaginationSort, 0, len(p.sorts))
+	for _, v := range p.sorts {
+		column, err := p.resolveSortColumn(v.Column)
+		if err != nil {
+			return nil, err
+		}
+		sorts = append(sorts, PaginationSort{Column: column, Descending: v.Descending})
+	}
+	return sorts, nil
+}
+
+func (p *Pagination[ModelType, PrimaryType, ResponseType]) resolveSortColumn(name string) (string, error) {
+	if column, ok := p.sortColumns[name]; ok {
+		return column, nil
+	}
+	if len(p.sortColumns) > 0 || len(p.sortOnlyColumns) > 0 {
+		for _, v := range p.sortOnlyColumns {
+			if v == name {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("unknown sort column: %s", name)
+	}
+	if !paginationSortColumnRegexp.MatchString(name) {
+		return "", fmt.Errorf("invalid sort column: %s", name)
+	}
+	return name, nil
+}
@@ -1,5 +1,31 @@
 package utils
 
+import "fmt"
+
 type IPresenter[ModelType IRepositoryModel, ResponseType any] interface {
 	Present(entity *ModelType) *ResponseType
 }
+
+// IBatchPresenter 可选的批量输出接口, 便于一次性预加载关联数据, 避免逐条查询
+type IBatchPresenter[ModelType IRepositoryModel, ResponseType any] interface {
+	PresentMany(entities []*ModelType) []*ResponseType
+}
+
+// PresentMany 优先使用 IBatchPresenter, 批量输出的数量须与 entities 一致
+func PresentMany[ModelType IRepositoryModel, ResponseType any](presenter IPresenter[ModelType, ResponseType], entities []*ModelType) ([]*ResponseType, error) {
+	if batch, ok := presenter.(IBatchPresenter[ModelType, ResponseType]); ok {
+		collection := batch.PresentMany(entities)
+		if len(collection) != len(entities) {
+			return nil, fmt.Errorf("batch presenter returned %d items for %d entities", len(collection), len(entities))
+		}
+		if collection == nil {
+			collection = make([]*ResponseType, 0)
+		}
+		return collection, nil
+	}
+	collection := make([]*ResponseType, 0, len(entities))
+	for _, v := range entities {
+		collection = append(collection, presenter.Present(v))
+	}
+	return collection, nil
+}
@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RepositoryAggregate struct {
+	Func   string
+	Column string
+	Alias  string
+}
+
+func AggregateCount(alias string) RepositoryAggregate {
+	return RepositoryAggregate{Func: "COUNT", Alias: alias}
+}
+
+func AggregateSum(column, alias string) RepositoryAggregate {
+	return RepositoryAggregate{Func: "SUM", Column: column, Alias: alias}
+}
+
+func AggregateAvg(column, alias string) RepositoryAggregate {
+	return RepositoryAggregate{Func: "AVG", Column: column, Alias: alias}
+}
+
+func AggregateMin(column, alias string) RepositoryAggregate {
+	return RepositoryAggregate{Func: "MIN", Column: column, Alias: alias}
+}
+
+func AggregateMax(column, alias string) RepositoryAggregate {
+	return RepositoryAggregate{Func: "MAX", Column: column, Alias: alias}
+}
+
+func (a RepositoryAggregate) expr() (string, []any, error) {
+	fn := strings.ToUpper(a.Func)
+	switch fn {
+	case "COUNT", "SUM", "AVG", "MIN", "MAX":
+	default:
+		return "", nil, fmt.Errorf("unsupported aggregate func: %s", a.Func)
+	}
+	if a.Alias == "" {
+		return "", nil, fmt.Errorf("alias of %s is required", fn)
+	}
+	if a.Column == "" {
+		if fn != "COUNT" {
+			return "", nil, fmt.Errorf("column of %s is required", fn)
+		}
+		return fn + "(*) AS ?", []any{clause.Column{Name: a.Alias}}, nil
+	}
+	return fn + "(?) AS ?", []any{clause.Column{Name: a.Column}, clause.Column{Name: a.Alias}}, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) Sum(column string, conditions map[string]any) (float64, error) {
+	var result *float64
+	if err := r.aggregate("SUM", column, conditions, &result); err != nil || result == nil {
+		return 0, err
+	}
+	return *result, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) Avg(column string, conditions map[string]any) (float64, error) {
+	var result *float64
+	if err := r.aggregate("AVG", column, conditions, &result); err != nil || result == nil {
+		return 0, err
+	}
+	return *result, nil
+}
+
+// Min dest 为可被 database/sql 扫描的指针, 无记录时为 NULL
+func (r Repository[ModelType, PrimaryType]) Min(column string, conditions map[string]any, dest any) error {
+	return r.aggregate("MIN", column, conditions, dest)
+}
+
+// Max dest 为可被 database/sql 扫描的指针, 无记录时为 NULL
+func (r Repository[ModelType, PrimaryType]) Max(column string, conditions map[string]any, dest any) error {
+	return r.aggregate("MAX", column, conditions, dest)
+}
+
+// Pluck dest 为切片指针
+func (r Repository[ModelType, PrimaryType]) Pluck(column string, conditions map[string]any, dest any) error {
+	column, err := r.resolveColumn(column)
+	if err != nil {
+		return err
+	}
+	return r.buildConditions(r.queryDB().Model(&r.model), conditions).Pluck(column, dest).Error
+}
+
+// Distinct dest 为切片指针
+func (r Repository[ModelType, PrimaryType]) Distinct(column string, conditions map[string]any, dest any) error {
+	column, err := r.resolveColumn(column)
+	if err != nil {
+		return err
+	}
+	return r.buildConditions(r.queryDB().Model(&r.model), conditions).Distinct().Pluck(column, dest).Error
+}
+
+// GroupBy 按 columns 分组聚合, dest 为结构体切片指针, 字段按列名/别名映射
+func (r Repository[ModelType, PrimaryType]) GroupBy(columns []string, aggregates []RepositoryAggregate, conditions map[string]any, dest any) error {
+	if len(columns) == 0 {
+		return fmt.Errorf("group by columns are required")
+	}
+	columns, err := r.resolveColumns(columns)
+	if err != nil {
+		return err
+	}
+
+	var (
+		selects = make([]string, 0, len(columns)+len(aggregates))
+		vars    = make([]any, 0, len(columns)+len(aggregates)*2)
+		groupBy = clause.GroupBy{}
+	)
+	for _, v := range columns {
+		selects = append(selects, "?")
+		vars = append(vars, clause.Column{Name: v})
+		groupBy.Columns = append(groupBy.Columns, clause.Column{Name: v})
+	}
+	for _, v := range aggregates {
+		if v.Column != "" {
+			if v.Column, err = r.resolveColumn(v.Column); err != nil {
+				return err
+			}
+		}
+		sql, args, err := v.expr()
+		if err != nil {
+			return err
+		}
+		selects = append(selects, sql)
+		vars = append(vars, args...)
+	}
+
+	builder := r.buildConditions(r.queryDB().Model(&r.model), conditions)
+	return builder.Select(strings.Join(selects, ", "), vars...).Clauses(groupBy).Scan(dest).Error
+}
+
+func RepositoryMin[T any, ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](r *Repository[ModelType, PrimaryType], column string, conditions map[string]any) (T, error) {
+	var result *T
+	if err := r.Min(column, conditions, &result); err != nil || result == nil {
+		return *new(T), err
+	}
+	return *result, nil
+}
+
+func RepositoryMax[T any, ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](r *Repository[ModelType, PrimaryType], column string, conditions map[string]any) (T, error) {
+	var result *T
+	if err := r.Max(column, conditions, &result); err != nil || result == nil {
+		return *new(T), err
+	}
+	return *result, nil
+}
+
+func RepositoryPluck[T any, ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](r *Repository[ModelType, PrimaryType], column string, conditions map[string]any) ([]T, error) {
+	result := make([]T, 0)
+	if err := r.Pluck(column, conditions, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func RepositoryDistinct[T any, ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](r *Repository[ModelType, PrimaryType], column string, conditions map[string]any) ([]T, error) {
+	result := make([]T, 0)
+	if err := r.Distinct(column, conditions, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func RepositoryGroupBy[T any, ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](r *Repository[ModelType, PrimaryType], columns []string, aggregates []RepositoryAggregate, conditions map[string]any) ([]*T, error) {
+	result := make([]*T, 0)
+	if err := r.GroupBy(columns, aggregates, conditions, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) aggregate(fn, column string, conditions map[string]any, dest any) error {
+	column, err := r.resolveColumn(column)
+	if err != nil {
+		return err
+	}
+	builder := r.buildConditions(r.queryDB().Model(&r.model), conditions)
+	rows, err := builder.Select(fn+"(?)", clause.Column{Name: column}).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if rows.Next() {
+		if err = rows.Scan(dest); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r Repository[ModelType, PrimaryType]) buildConditions(builder *gorm.DB, conditions map[string]any) *gorm.DB {
+	for k, v := range conditions {
+		builder = r.buildWhereCondition(builder, k, v)
+	}
+	return builder
+}
@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const repositoryAuditOldsKey = "audit.olds"
+
+type RepositoryAuditChange struct {
+	Old any `json:"old"`
+	New any `json:"new"`
+}
+
+type RepositoryAuditEntry struct {
+	Table      string                           `json:"table"`
+	Action     RepositoryAction                 `json:"action"`
+	PrimaryKey any                              `json:"primary_key"`
+	Operator   string                           `json:"operator"`
+	Changes    map[string]RepositoryAuditChange `json:"changes"`
+	CreatedAt  time.Time                        `json:"created_at"`
+}
+
+type IRepositoryAuditWriter interface {
+	WriteAudit(entries []*RepositoryAuditEntry) error
+}
+
+// RepositoryAuditStreamWriter 每条记录输出一行 JSON
+type RepositoryAuditStreamWriter struct {
+	mux sync.Mutex
+	out io.Writer
+}
+
+func NewRepositoryAuditStreamWriter(out io.Writer) *RepositoryAuditStreamWriter {
+	return &RepositoryAuditStreamWriter{out: out}
+}
+
+func (w *RepositoryAuditStreamWriter) WriteAudit(entries []*RepositoryAuditEntry) error {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	encoder := json.NewEncoder(w.out)
+	for _, v := range entries {
+		if err := encoder.Encode(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RepositoryAuditTableWriter 写入审计表, 表需包含列: table_name, action, primary_key, operator, changes, created_at
+type RepositoryAuditTableWriter struct {
+	db    *gorm.DB
+	table string
+}
+
+func NewRepositoryAuditTableWriter(db *gorm.DB, table string) *RepositoryAuditTableWriter {
+	return &RepositoryAuditTableWriter{db: db, table: table}
+}
+
+func (w *RepositoryAuditTableWriter) WriteAudit(entries []*RepositoryAuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	rows := make([]map[string]any, 0, len(entries))
+	for _, v := range entries {
+		changes, err := json.Marshal(v.Changes)
+		if err != nil {
+			return err
+		}
+		primaryKey, err := json.Marshal(v.PrimaryKey)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, map[string]any{
+			"table_name":  v.Table,
+			"action":      string(v.Action),
+			"primary_key": string(primaryKey),
+			"operator":    v.Operator,
+			"changes":     string(changes),
+			"created_at":  v.CreatedAt,
+		})
+	}
+	return w.db.Table(w.table).Create(rows).Error
+}
+
+// EnableAudit 通过钩子记录每行数据各列的新旧值, 更新/删除/Upsert 前会以发起写操作的 Repository(含租户与上下文)按条件分批查询旧数据;
+// UpdateAll/DeleteAll 等全表写入会读取并审计整张表, 大表上应避免在启用审计的 Repository 上执行
+func (r *Repository[ModelType, PrimaryType]) EnableAudit(writer IRepositoryAuditWriter, operator func(ctx context.Context) string) *Repository[ModelType, PrimaryType] {
+	loadOlds := func(hc *RepositoryHookContext[ModelType]) error {
+		//Upsert 没有可冲突的记录时无需读取
+		if hc.Conditions == nil {
+			return nil
+		}
+		//按主键分批读取, 全表写入时不会一次查询整张表, 但旧数据仍全部保留在内存中直到写入审计
+		olds := make([]map[string]any, 0)
+		if err := hc.load(hc.Conditions, func(entities []*ModelType) error {
+			for _, v := range entities {
+				m, err := GormEntityToMapWithNamer(v, r.db.NamingStrategy)
+				if err != nil {
+					return err
+				}
+				olds = append(olds, m)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		hc.Store[repositoryAuditOldsKey] = olds
+		return nil
+	}
+	write := func(hc *RepositoryHookContext[ModelType]) error {
+		entries, err := r.buildAuditEntries(hc)
+		if err != nil || len(entries) == 0 {
+			return err
+		}
+		if operator != nil {
+			name := operator(hc.Context)
+			for _, v := range entries {
+				v.Operator = name
+			}
+		}
+		return writer.WriteAudit(entries)
+	}
+
+	r.Before(RepositoryActionUpdate, func(hc *RepositoryHookContext[ModelType]) error {
+		return loadOlds(hc)
+	})
+	r.Before(RepositoryActionDelete, func(hc *RepositoryHookContext[ModelType]) error {
+		return loadOlds(hc)
+	})
+	r.Before(RepositoryActionUpsert, func(hc *RepositoryHookContext[ModelType]) error {
+		return loadOlds(hc)
+	})
+	r.After(RepositoryActionCreate, write)
+	r.After(RepositoryActionUpdate, write)
+	r.After(RepositoryActionDelete, write)
+	r.After(RepositoryActionUpsert, write)
+	return r
+}
+
+func (r Repository[ModelType, PrimaryType]) buildAuditEntries(hc *RepositoryHookContext[ModelType]) ([]*RepositoryAuditEntry, error) {
+	var (
+		now     = time.Now()
+		pk      = r.model.PrimaryKey()
+		entries = make([]*RepositoryAuditEntry, 0)
+		olds, _ = hc.Store[repositoryAuditOldsKey].([]map[string]any)
+	)
+	newEntry := func(primaryKey any, changes map[string]RepositoryAuditChange) {
+		if len(changes) > 0 {
+			entries = append(entries, &RepositoryAuditEntry{Table: hc.Table, Action: hc.Action, PrimaryKey: primaryKey, Changes: changes, CreatedAt: now})
+		}
+	}
+
+	switch hc.Action {
+	case RepositoryActionCreate:
+		for _, entity := range hc.Entities {
+			m, err := GormEntityToMapWithNamer(entity, r.db.NamingStrategy)
+			if err != nil {
+				return nil, err
+			}
+			changes := make(map[string]RepositoryAuditChange, len(m))
+			for k, v := range m {
+				changes[k] = RepositoryAuditChange{New: v}
+			}
+			newEntry(m[pk], changes)
+		}
+
+	case RepositoryActionUpdate:
+		params := make(map[string]any)
+		for k, v := range hc.Params {
+			if _, ok := v.(clause.Expression); ok {
+				continue
+			}
+			if valuer, ok := v.(driver.Valuer); ok {
+				v, _ = valuer.Value()
+			}
+			params[k] = v
+		}
+		for _, entity := range hc.Entities {
+			m, err := GormEntityToMapWithNamer(entity, r.db.NamingStrategy)
+			if err != nil {
+				return nil, err
+			}
+			if len(hc.Fields) > 0 {
+				for _, field := range hc.Fields {
+					if v, ok := m[field]; ok {
+						params[field] = v
+					}
+				}
+			} else {
+				for k, v := range m {
+					params[k] = v
+				}
+			}
+		}
+		for _, old := range olds {
+			changes := make(map[string]RepositoryAuditChange)
+			for k, v := range params {
+				if !gormValueEqual(old[k], v) {
+					changes[k] = RepositoryAuditChange{Old: old[k], New: v}
+				}
+			}
+			newEntry(old[pk], changes)
+		}
+
+	case RepositoryActionUpsert:
+		//按冲突列匹配旧数据, 未匹配的为插入, 匹配的仅记录冲突时更新的列
+		conflictColumns := hc.ConflictColumns
+		if len(conflictColumns) == 0 {
+			conflictColumns = r.primaryKeys()
+		}
+		conflictKey := func(m map[string]any) string {
+			values := make([]any, 0, len(conflictColumns))
+			for _, v := range conflictColumns {
+				values = append(values, m[v])
+			}
+			return fmt.Sprintf("%#v", values)
+		}
+		indexed := make(map[string]map[string]any, len(olds))
+		for _, old := range olds {
+			indexed[conflictKey(old)] = old
+		}
+		primaryKeys := make(map[string]bool)
+		for _, v := range r.primaryKeys() {
+			primaryKeys[v] = true
+		}
+		for _, entity := range hc.Entities {
+			m, err := GormEntityToMapWithNamer(entity, r.db.NamingStrategy)
+			if err != nil {
+				return nil, err
+			}
+			old, ok := indexed[conflictKey(m)]
+			if !ok {
+				changes := make(map[string]RepositoryAuditChange, len(m))
+				for k, v := range m {
+					changes[k] = RepositoryAuditChange{New: v}
+				}
+				newEntry(m[pk], changes)
+				continue
+			}
+			columns := hc.Fields
+			if len(columns) == 0 {
+				columns = make([]string, 0, len(m))
+				for k := range m {
+					if !primaryKeys[k] {
+						columns = append(columns, k)
+					}
+				}
+			}
+			changes := make(map[string]RepositoryAuditChange)
+			for _, k := range columns {
+				if v, ok := m[k]; ok && !gormValueEqual(old[k], v) {
+					changes[k] = RepositoryAuditChange{Old: old[k], New: v}
+				}
+			}
+			newEntry(old[pk], changes)
+		}
+
+	case RepositoryActionDelete:
+		for _, old := range olds {
+			changes := make(map[string]RepositoryAuditChange, len(old))
+			for k, v := range old {
+				changes[k] = RepositoryAuditChange{Old: v}
+			}
+			newEntry(old[pk], changes)
+		}
+	}
+	return entries, nil
+}
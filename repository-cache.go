@@ -0,0 +1,321 @@
+package utils
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// IRepositoryCacheStore 缓存存储, value 为 nil 表示缓存的未命中记录
+type IRepositoryCacheStore interface {
+	Get(key string) (value any, ok bool)
+	Set(key string, value any, ttl time.Duration)
+	Delete(keys ...string)
+	DeletePrefix(prefix string)
+}
+
+type repositoryCacheEntry struct {
+	key      string
+	value    any
+	expireAt time.Time
+	element  *list.Element
+}
+
+type RepositoryCacheMemory struct {
+	capacity int
+	entries  *Map[string, *repositoryCacheEntry]
+	lru      *list.List
+}
+
+// NewRepositoryCacheMemory 内存 LRU+TTL 缓存, capacity <= 0 时不限制数量
+func NewRepositoryCacheMemory(capacity int) *RepositoryCacheMemory {
+	return &RepositoryCacheMemory{
+		capacity: capacity,
+		entries:  NewMap[string, *repositoryCacheEntry](),
+		lru:      list.New(),
+	}
+}
+
+func (c *RepositoryCacheMemory) Get(key string) (value any, ok bool) {
+	c.entries.WithLock(func(m map[string]*repositoryCacheEntry) {
+		var entry *repositoryCacheEntry
+		if entry, ok = m[key]; !ok {
+			return
+		}
+		if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+			c.lru.Remove(entry.element)
+			delete(m, key)
+			ok = false
+			return
+		}
+		c.lru.MoveToFront(entry.element)
+		value = entry.value
+	})
+	return
+}
+
+func (c *RepositoryCacheMemory) Set(key string, value any, ttl time.Duration) {
+	var expireAt time.Time
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl)
+	}
+	c.entries.WithLock(func(m map[string]*repositoryCacheEntry) {
+		if entry, ok := m[key]; ok {
+			entry.value, entry.expireAt = value, expireAt
+			c.lru.MoveToFront(entry.element)
+			return
+		}
+		entry := &repositoryCacheEntry{key: key, value: value, expireAt: expireAt}
+		entry.element = c.lru.PushFront(entry)
+		m[key] = entry
+
+		for c.capacity > 0 && c.lru.Len() > c.capacity {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(m, oldest.Value.(*repositoryCacheEntry).key)
+		}
+	})
+}
+
+func (c *RepositoryCacheMemory) Delete(keys ...string) {
+	c.entries.WithLock(func(m map[string]*repositoryCacheEntry) {
+		for _, key := range keys {
+			if entry, ok := m[key]; ok {
+				c.lru.Remove(entry.element)
+				delete(m, key)
+			}
+		}
+	})
+}
+
+func (c *RepositoryCacheMemory) DeletePrefix(prefix string) {
+	c.entries.WithLock(func(m map[string]*repositoryCacheEntry) {
+		for key, entry := range m {
+			if strings.HasPrefix(key, prefix) {
+				c.lru.Remove(entry.element)
+				delete(m, key)
+			}
+		}
+	})
+}
+
+type RepositoryCacheConfig struct {
+	TTL time.Duration
+	//未命中记录的缓存时间, 为 0 时不缓存未命中
+	NegativeTTL time.Duration
+}
+
+// CachedRepository 为 Get/GetIn 提供读穿透缓存, 缓存失效注册为被包装 Repository 的 After 钩子,
+// 经 WithContext/WithTenant 等派生的 Repository 写入后同样失效; 钩子须在派生前注册, 即先创建 CachedRepository
+// WithContext/WithTenant/WithoutTenant/Primary/Strict 返回共用缓存的 CachedRepository, WithTrashed/OnlyTrashed 不走缓存
+// 带 preloads 的查询不走缓存; 按条件写入时失效整张表的缓存
+type CachedRepository[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey] struct {
+	*Repository[ModelType, PrimaryType]
+	store  IRepositoryCacheStore
+	config RepositoryCacheConfig
+}
+
+func NewCachedRepository[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](
+	repo *Repository[ModelType, PrimaryType], store IRepositoryCacheStore, config RepositoryCacheConfig,
+) *CachedRepository[ModelType, PrimaryType] {
+	if store == nil {
+		store = NewRepositoryCacheMemory(10000)
+	}
+	r := &CachedRepository[ModelType, PrimaryType]{Repository: repo, store: store, config: config}
+	//同一 Repository 与 store 只注册一次失效钩子
+	repo.initHooks()
+	if repo.hooks.register(store) {
+		for _, action := range []RepositoryAction{RepositoryActionCreate, RepositoryActionUpdate, RepositoryActionDelete, RepositoryActionUpsert} {
+			repo.After(action, r.invalidateHook)
+		}
+	}
+	return r
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) WithContext(ctx context.Context) *CachedRepository[ModelType, PrimaryType] {
+	return r.derive(r.Repository.WithContext(ctx))
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) WithTenant(tenant RepositoryTenant) *CachedRepository[ModelType, PrimaryType] {
+	return r.derive(r.Repository.WithTenant(tenant))
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) WithoutTenant() *CachedRepository[ModelType, PrimaryType] {
+	return r.derive(r.Repository.WithoutTenant())
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) Primary() *CachedRepository[ModelType, PrimaryType] {
+	return r.derive(r.Repository.Primary())
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) Strict() *CachedRepository[ModelType, PrimaryType] {
+	return r.derive(r.Repository.Strict())
+}
+
+// derive 包装派生的 Repository, 共用缓存与已注册的失效钩子
+func (r CachedRepository[ModelType, PrimaryType]) derive(repo *Repository[ModelType, PrimaryType]) *CachedRepository[ModelType, PrimaryType] {
+	return &CachedRepository[ModelType, PrimaryType]{Repository: repo, store: r.store, config: r.config}
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) Get(id PrimaryType, preloads ...string) (*ModelType, error) {
+	if len(preloads) > 0 {
+		return r.Repository.Get(id, preloads...)
+	}
+	key := r.cacheKey(id)
+	if value, ok := r.store.Get(key); ok {
+		if value == nil {
+			return nil, ErrNotFound
+		}
+		//共享存储中类型不符的值视为未命中
+		if entity, ok := value.(ModelType); ok {
+			return &entity, nil
+		}
+	}
+
+	entity, err := r.Repository.Get(id)
+	if err == ErrNotFound && r.config.NegativeTTL > 0 {
+		r.store.Set(key, nil, r.config.NegativeTTL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.store.Set(key, *entity, r.config.TTL)
+	return entity, nil
+}
+
+// GetIn 按传入 ids 的顺序返回记录
+func (r CachedRepository[ModelType, PrimaryType]) GetIn(values []PrimaryType, preloads ...string) ([]*ModelType, error) {
+	if len(preloads) > 0 || r.isCompositePrimaryKey() {
+		return r.Repository.GetIn(values, preloads...)
+	}
+
+	var (
+		found  = make(map[PrimaryType]*ModelType)
+		misses = make([]PrimaryType, 0)
+	)
+	for _, id := range values {
+		if value, ok := r.store.Get(r.cacheKey(id)); ok {
+			if value == nil {
+				continue
+			}
+			if entity, ok := value.(ModelType); ok {
+				found[id] = &entity
+				continue
+			}
+		}
+		misses = append(misses, id)
+	}
+
+	if len(misses) > 0 {
+		entities, err := r.Repository.GetIn(misses)
+		if err != nil {
+			return nil, err
+		}
+		for _, entity := range entities {
+			id, err := r.primaryKeyOf(entity)
+			if err != nil {
+				return nil, err
+			}
+			found[id] = entity
+			r.store.Set(r.cacheKey(id), *entity, r.config.TTL)
+		}
+		if r.config.NegativeTTL > 0 {
+			for _, id := range misses {
+				if _, ok := found[id]; !ok {
+					r.store.Set(r.cacheKey(id), nil, r.config.NegativeTTL)
+				}
+			}
+		}
+	}
+
+	result := make([]*ModelType, 0, len(found))
+	for _, id := range SliceUnique(values) {
+		if entity, ok := found[id]; ok {
+			result = append(result, entity)
+		}
+	}
+	return result, nil
+}
+
+// invalidateHook 按钩子上下文中的实体或主键条件失效缓存, 无法确定主键时(含按非主键冲突的 Upsert)失效整张表
+func (r CachedRepository[ModelType, PrimaryType]) invalidateHook(hc *RepositoryHookContext[ModelType]) error {
+	if hc.Action == RepositoryActionUpsert && len(hc.ConflictColumns) > 0 && !reflect.DeepEqual(hc.ConflictColumns, r.primaryKeys()) {
+		r.invalidateAll()
+		return nil
+	}
+	if len(hc.Entities) > 0 {
+		r.invalidateEntities(hc.Entities...)
+		return nil
+	}
+	if len(hc.Conditions) == 1 {
+		switch v := hc.Conditions[r.model.PrimaryKey()].(type) {
+		case PrimaryType:
+			r.invalidate(v)
+			return nil
+		case []PrimaryType:
+			r.invalidate(v...)
+			return nil
+		}
+	}
+	r.invalidateAll()
+	return nil
+}
+
+// invalidate 失效 ids 在所有租户下的缓存
+func (r CachedRepository[ModelType, PrimaryType]) invalidate(ids ...PrimaryType) {
+	for _, id := range ids {
+		r.store.DeletePrefix(r.cachePrefix(id))
+	}
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) invalidateEntities(entities ...*ModelType) {
+	ids := make([]PrimaryType, 0, len(entities))
+	for _, entity := range entities {
+		if entity == nil {
+			continue
+		}
+		id, err := r.primaryKeyOf(entity)
+		if err != nil {
+			r.invalidateAll()
+			return
+		}
+		ids = append(ids, id)
+	}
+	r.invalidate(ids...)
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) invalidateAll() {
+	r.store.DeletePrefix(r.TableName() + ":")
+}
+
+// cacheKey 格式为 "表名:主键:租户", 便于按主键失效所有租户下的缓存
+func (r CachedRepository[ModelType, PrimaryType]) cacheKey(id PrimaryType) string {
+	if r.tenant != nil {
+		tenant, _ := r.tenantValue()
+		return fmt.Sprintf("%s%v", r.cachePrefix(id), tenant)
+	}
+	return r.cachePrefix(id)
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) cachePrefix(id PrimaryType) string {
+	return fmt.Sprintf("%s:%v:", r.TableName(), id)
+}
+
+func (r CachedRepository[ModelType, PrimaryType]) primaryKeyOf(entity *ModelType) (id PrimaryType, err error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return
+	}
+	field := sch.LookUpField(r.model.PrimaryKey())
+	if field == nil {
+		return id, fmt.Errorf("primary key %s not found in %s", r.model.PrimaryKey(), sch.Name)
+	}
+	value := reflect.Indirect(field.ReflectValueOf(context.Background(), reflect.ValueOf(entity).Elem()))
+	if !value.Type().ConvertibleTo(reflect.TypeOf(id)) || (value.Kind() == reflect.String) != (reflect.TypeOf(id).Kind() == reflect.String) {
+		return id, fmt.Errorf("primary key %s is not of type %T", r.model.PrimaryKey(), id)
+	}
+	return value.Convert(reflect.TypeOf(id)).Interface().(PrimaryType), nil
+}
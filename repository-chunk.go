@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/clause"
+)
+
+// Chunk 按主键升序(keyset)分批遍历满足条件的记录, fn 返回 false 或 ctx 取消时停止
+func (r Repository[ModelType, PrimaryType]) Chunk(ctx context.Context, conditions map[string]any, batchSize int, fn func(entities []*ModelType) bool, preloads ...string) error {
+	if r.isCompositePrimaryKey() {
+		return fmt.Errorf("chunk does not support composite primary keys")
+	}
+	sch, err := r.parseSchema()
+	if err != nil {
+		return err
+	}
+	pkField := sch.LookUpField(r.model.PrimaryKey())
+	if pkField == nil {
+		return fmt.Errorf("primary key %s not found in %s", r.model.PrimaryKey(), sch.Name)
+	}
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	var (
+		last   any
+		column = clause.Column{Table: clause.CurrentTable, Name: pkField.DBName}
+	)
+	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+
+		entities := make([]*ModelType, 0, batchSize)
+		builder := r.buildPreloads(r.queryDB().WithContext(ctx), preloads...)
+		for k, v := range conditions {
+			builder = r.buildWhereCondition(builder, k, v)
+		}
+		if last != nil {
+			builder = builder.Where(clause.Gt{Column: column, Value: last})
+		}
+		if err = builder.Order(clause.OrderByColumn{Column: column}).Limit(batchSize).Find(&entities).Error; err != nil {
+			return err
+		}
+		if len(entities) == 0 {
+			return nil
+		}
+		if !fn(entities) {
+			return nil
+		}
+		if len(entities) < batchSize {
+			return nil
+		}
+		last, _ = pkField.ValueOf(ctx, reflect.ValueOf(entities[len(entities)-1]).Elem())
+	}
+}
+
+// Each 逐条遍历, 可直接配合 Pipeline.Queue 使用
+func (r Repository[ModelType, PrimaryType]) Each(ctx context.Context, conditions map[string]any, batchSize int, fn func(entity *ModelType) bool, preloads ...string) error {
+	return r.Chunk(ctx, conditions, batchSize, func(entities []*ModelType) bool {
+		for _, v := range entities {
+			if !fn(v) {
+				return false
+			}
+		}
+		return true
+	}, preloads...)
+}
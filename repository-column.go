@@ -0,0 +1,171 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// repositoryJsonColumns 缓存各模型 json 名到列名的映射
+var repositoryJsonColumns sync.Map
+
+// resolveColumn 校验列名并转换为数据库列名, 支持列名、结构体字段名、json 名及 "表名.列名"
+func (r Repository[ModelType, PrimaryType]) resolveColumn(name string) (string, error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return "", err
+	}
+
+	table, column := "", strings.TrimSpace(name)
+	if i := strings.LastIndex(column, "."); i >= 0 {
+		table, column = column[:i], column[i+1:]
+		if table != sch.Table && table != r.TableName() {
+			return "", fmt.Errorf("column %q does not belong to table %s", name, r.TableName())
+		}
+	}
+
+	field := repositoryLookUpField(sch, column)
+	if field == nil {
+		return "", fmt.Errorf("unknown column %q for table %s", name, r.TableName())
+	}
+	if table != "" {
+		return table + "." + field.DBName, nil
+	}
+	return field.DBName, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) resolveColumns(names []string) ([]string, error) {
+	columns := make([]string, 0, len(names))
+	for _, v := range names {
+		column, err := r.resolveColumn(v)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, nil
+}
+
+// resolveParams 转换更新参数的键, 值不变
+func (r Repository[ModelType, PrimaryType]) resolveParams(params map[string]any) (map[string]any, error) {
+	resolved := make(map[string]any, len(params))
+	for k, v := range params {
+		column, err := r.resolveColumn(k)
+		if err != nil {
+			return nil, err
+		}
+		resolved[column] = v
+	}
+	return resolved, nil
+}
+
+// resolveExpression 校验并转换比较表达式中的列名, 如 FilterConditions 生成的条件;
+// clause.Expr 等其他表达式由调用方构造, 原样返回
+func (r Repository[ModelType, PrimaryType]) resolveExpression(expr clause.Expression) (clause.Expression, error) {
+	var err error
+	switch e := expr.(type) {
+	case clause.Eq:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Neq:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Gt:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Gte:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Lt:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Lte:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.Like:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.IN:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case filterLike:
+		e.Column, err = r.resolveExpressionColumn(e.Column)
+		return e, err
+	case clause.NotConditions:
+		e.Exprs, err = r.resolveExpressions(e.Exprs)
+		return e, err
+	case clause.AndConditions:
+		e.Exprs, err = r.resolveExpressions(e.Exprs)
+		return e, err
+	case clause.OrConditions:
+		e.Exprs, err = r.resolveExpressions(e.Exprs)
+		return e, err
+	}
+	return expr, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) resolveExpressions(exprs []clause.Expression) ([]clause.Expression, error) {
+	resolved := make([]clause.Expression, 0, len(exprs))
+	for _, v := range exprs {
+		expr, err := r.resolveExpression(v)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, expr)
+	}
+	return resolved, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) resolveExpressionColumn(column any) (any, error) {
+	switch c := column.(type) {
+	case string:
+		return r.resolveColumn(c)
+	case clause.Column:
+		if c.Raw || c.Name == clause.PrimaryKey {
+			return c, nil
+		}
+		name := c.Name
+		if c.Table != "" && c.Table != clause.CurrentTable {
+			name = c.Table + "." + c.Name
+		}
+		resolved, err := r.resolveColumn(name)
+		if err != nil {
+			return nil, err
+		}
+		c.Name = resolved[strings.LastIndex(resolved, ".")+1:]
+		return c, nil
+	}
+	return column, nil
+}
+
+// repositoryLookUpField 按列名、结构体字段名或 json 名查找有对应列的字段
+func repositoryLookUpField(sch *schema.Schema, name string) *schema.Field {
+	if field := sch.LookUpField(name); field != nil && field.DBName != "" {
+		return field
+	}
+	if column, ok := repositorySchemaJsonColumns(sch)[name]; ok {
+		return sch.LookUpField(column)
+	}
+	return nil
+}
+
+func repositorySchemaJsonColumns(sch *schema.Schema) map[string]string {
+	if v, ok := repositoryJsonColumns.Load(sch); ok {
+		return v.(map[string]string)
+	}
+	columns := make(map[string]string)
+	for _, field := range sch.Fields {
+		if field.DBName == "" {
+			continue
+		}
+		name, _, _ := strings.Cut(field.StructField.Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			columns[name] = field.DBName
+		}
+	}
+	v, _ := repositoryJsonColumns.LoadOrStore(sch, columns)
+	return v.(map[string]string)
+}
@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// FilterConditions 将过滤结构体转换为 Repository 的条件, 字段通过 filter 标签声明列与操作符:
+//
+//	type UserFilter struct {
+//		Status []int        `form:"status" filter:"op=in"`
+//		Name   string       `form:"name" filter:"column=user_name,op=like"`
+//		From   JsonNullTime `form:"from" filter:"column=created_at,op=gte"`
+//	}
+//
+// 操作符: eq(默认), ne, gt, gte, lt, lte, like(包含, 输入中的 % _ 按字面匹配), in, notin, null(布尔值, true 为 IS NULL)
+// nil 指针、非指针字段的零值、空切片、未设置的 EnumInt/EnumString 及 Valid 为 false 的 JsonNull* 均不产生条件
+// eq/in 以列名为键, 其余操作符以 "列名 操作符" 为键、clause.Expression 为值;
+// 列名可以是结构体字段名或 json 名, 由 Repository 在查询时校验并转换为数据库列名
+func FilterConditions(filter any) (map[string]any, error) {
+	rValue := reflect.ValueOf(filter)
+	for rValue.Kind() == reflect.Ptr {
+		if rValue.IsNil() {
+			return map[string]any{}, nil
+		}
+		rValue = rValue.Elem()
+	}
+	if rValue.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("invalid filter param: %T", filter)
+	}
+
+	conditions := make(map[string]any)
+	if err := filterConditions(rValue, conditions); err != nil {
+		return nil, err
+	}
+	return conditions, nil
+}
+
+func filterConditions(rValue reflect.Value, conditions map[string]any) error {
+	rType := rValue.Type()
+	for i := 0; i < rValue.NumField(); i++ {
+		tField := rType.Field(i)
+		tag, hasTag := tField.Tag.Lookup("filter")
+		if tag == "-" || !tField.IsExported() {
+			continue
+		}
+		if !hasTag {
+			//未声明标签的嵌入结构体展开处理, 其余字段忽略
+			if tField.Anonymous && rValue.Field(i).Kind() == reflect.Struct {
+				if err := filterConditions(rValue.Field(i), conditions); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		column, op := schema.NamingStrategy{}.ColumnName("", tField.Name), "eq"
+		for _, v := range strings.Split(tag, ",") {
+			kv := strings.SplitN(strings.TrimSpace(v), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case "column":
+				column = strings.TrimSpace(kv[1])
+			case "op":
+				op = strings.ToLower(strings.TrimSpace(kv[1]))
+			}
+		}
+
+		value, ok, err := filterValue(rValue.Field(i))
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", tField.Name, err)
+		}
+		if !ok {
+			continue
+		}
+		key, condition, err := filterCondition(column, op, value)
+		if err != nil {
+			return fmt.Errorf("filter %s: %w", tField.Name, err)
+		}
+		conditions[key] = condition
+	}
+	return nil
+}
+
+// filterValue 取出字段的值, ok 为 false 表示未设置, 非 nil 指针指向的零值视为已设置
+func filterValue(vField reflect.Value) (any, bool, error) {
+	isPtr := vField.Kind() == reflect.Ptr
+	for vField.Kind() == reflect.Ptr {
+		if vField.IsNil() {
+			return nil, false, nil
+		}
+		vField = vField.Elem()
+	}
+
+	if s, ok := vField.Interface().(interface{ IsSet() bool }); ok && !s.IsSet() {
+		return nil, false, nil
+	}
+	if valuer, ok := vField.Interface().(driver.Valuer); ok {
+		value, err := valuer.Value()
+		if err != nil || value == nil {
+			return nil, false, err
+		}
+		return value, true, nil
+	}
+
+	switch vField.Kind() {
+	case reflect.Slice, reflect.Array:
+		if vField.Len() == 0 {
+			return nil, false, nil
+		}
+	default:
+		if !isPtr && vField.IsZero() {
+			return nil, false, nil
+		}
+	}
+	return vField.Interface(), true, nil
+}
+
+func filterCondition(column, op string, value any) (string, any, error) {
+	col := clause.Column{Name: column}
+	switch op {
+	case "eq":
+		return column, value, nil
+	case "in":
+		if k := reflect.TypeOf(value).Kind(); k != reflect.Slice && k != reflect.Array {
+			value = []any{value}
+		}
+		return column, value, nil
+	case "ne":
+		return column + " " + op, clause.Neq{Column: col, Value: value}, nil
+	case "gt":
+		return column + " " + op, clause.Gt{Column: col, Value: value}, nil
+	case "gte":
+		return column + " " + op, clause.Gte{Column: col, Value: value}, nil
+	case "lt":
+		return column + " " + op, clause.Lt{Column: col, Value: value}, nil
+	case "lte":
+		return column + " " + op, clause.Lte{Column: col, Value: value}, nil
+	case "like":
+		return column + " " + op, filterLike{Column: col, Value: "%" + filterLikeEscaper.Replace(fmt.Sprint(value)) + "%"}, nil
+	case "notin":
+		if k := reflect.TypeOf(value).Kind(); k != reflect.Slice && k != reflect.Array {
+			value = []any{value}
+		}
+		return column + " " + op, clause.Not(clause.IN{Column: col, Values: SliceToAnySlice(value)}), nil
+	case "null":
+		isNull, ok := value.(bool)
+		if !ok {
+			return "", nil, fmt.Errorf("op null requires a bool value")
+		}
+		if isNull {
+			return column + " " + op, clause.Eq{Column: col, Value: nil}, nil
+		}
+		return column + " " + op, clause.Neq{Column: col, Value: nil}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported filter op: %s", op)
+	}
+}
+
+// filterLikeEscaper 转义 LIKE 的通配符, 使用 ! 作为转义符, 各数据库对反斜杠的处理不一致
+var filterLikeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// filterLike 生成 `column LIKE ? ESCAPE '!'`
+type filterLike struct {
+	Column any
+	Value  any
+}
+
+func (like filterLike) Build(builder clause.Builder) {
+	builder.WriteQuoted(like.Column)
+	builder.WriteString(" LIKE ")
+	builder.AddVar(builder, like.Value)
+	builder.WriteString(" ESCAPE '!'")
+}
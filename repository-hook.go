@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+)
+
+const repositoryHookChunkSize = 500
+
+type RepositoryAction string
+
+const (
+	RepositoryActionCreate RepositoryAction = "create"
+	RepositoryActionUpdate RepositoryAction = "update"
+	RepositoryActionDelete RepositoryAction = "delete"
+	//Upsert 同时包含插入与冲突时的更新
+	RepositoryActionUpsert RepositoryAction = "upsert"
+)
+
+// RepositoryHookContext 同一次写操作的 before/after 钩子共享同一个上下文
+type RepositoryHookContext[ModelType IRepositoryModel] struct {
+	Context context.Context
+	Action  RepositoryAction
+	Table   string
+
+	//更新/删除的条件, 按主键操作时为 {主键: id 或 ids}, 全表操作时为空 map
+	Conditions map[string]any
+	//更新的列值, Save 时为空, 由 Entities 与 Fields 表示
+	Params map[string]any
+	Fields []string
+	//Create/Save/Upsert 的实体
+	Entities []*ModelType
+	//Upsert 的冲突列, 为空时为主键
+	ConflictColumns []string
+	//写操作包括已软删除的记录, 如 Restore/ForceDelete
+	Unscoped bool
+
+	RowsAffected int64
+	//供钩子之间传递数据
+	Store map[string]any
+
+	//以发起本次写操作的 Repository(含租户、上下文)从主库读取命中的记录
+	load func(conditions map[string]any, fn func(entities []*ModelType) error) error
+}
+
+type RepositoryHookFunc[ModelType IRepositoryModel] func(hc *RepositoryHookContext[ModelType]) error
+
+type repositoryHooks[ModelType IRepositoryModel] struct {
+	mux    sync.RWMutex
+	before map[RepositoryAction][]RepositoryHookFunc[ModelType]
+	after  map[RepositoryAction][]RepositoryHookFunc[ModelType]
+	//已注册钩子的组件, 如 CachedRepository 的缓存存储
+	registered map[any]struct{}
+}
+
+// Before 注册写操作前的钩子, 返回错误时中止本次操作
+func (r *Repository[ModelType, PrimaryType]) Before(action RepositoryAction, fn RepositoryHookFunc[ModelType]) *Repository[ModelType, PrimaryType] {
+	r.initHooks()
+	r.hooks.mux.Lock()
+	defer r.hooks.mux.Unlock()
+	r.hooks.before[action] = append(r.hooks.before[action], fn)
+	return r
+}
+
+// After 注册写操作成功后的钩子, 某个钩子返回错误时其余钩子仍会执行, 错误合并后返回
+func (r *Repository[ModelType, PrimaryType]) After(action RepositoryAction, fn RepositoryHookFunc[ModelType]) *Repository[ModelType, PrimaryType] {
+	r.initHooks()
+	r.hooks.mux.Lock()
+	defer r.hooks.mux.Unlock()
+	r.hooks.after[action] = append(r.hooks.after[action], fn)
+	return r
+}
+
+func (r *Repository[ModelType, PrimaryType]) initHooks() {
+	if r.hooks == nil {
+		r.hooks = newRepositoryHooks[ModelType]()
+	}
+}
+
+// register 记录 key 对应的钩子已注册, 首次注册时返回 true; 不可比较的 key 每次都返回 true
+func (h *repositoryHooks[ModelType]) register(key any) bool {
+	if key == nil || !reflect.TypeOf(key).Comparable() {
+		return true
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.registered[key]; ok {
+		return false
+	}
+	h.registered[key] = struct{}{}
+	return true
+}
+
+func newRepositoryHooks[ModelType IRepositoryModel]() *repositoryHooks[ModelType] {
+	return &repositoryHooks[ModelType]{
+		before:     make(map[RepositoryAction][]RepositoryHookFunc[ModelType]),
+		after:      make(map[RepositoryAction][]RepositoryHookFunc[ModelType]),
+		registered: make(map[any]struct{}),
+	}
+}
+
+func (r Repository[ModelType, PrimaryType]) withHooks(hc *RepositoryHookContext[ModelType], fn func() (int64, error)) (int64, error) {
+	if err := r.guardTenant(hc); err != nil {
+		return 0, err
+	}
+	if r.hooks == nil {
+		return fn()
+	}
+
+	r.hooks.mux.RLock()
+	before, after := r.hooks.before[hc.Action], r.hooks.after[hc.Action]
+	r.hooks.mux.RUnlock()
+
+	if len(before) == 0 && len(after) == 0 {
+		return fn()
+	}
+
+	hc.Context, hc.Table, hc.Store = r.db.Statement.Context, r.TableName(), make(map[string]any)
+	hc.load = func(conditions map[string]any, fn func(entities []*ModelType) error) error {
+		return r.loadAffected(conditions, hc.Unscoped, fn)
+	}
+	for _, hook := range before {
+		if err := hook(hc); err != nil {
+			return 0, err
+		}
+	}
+
+	rowsAffected, err := fn()
+	if err != nil {
+		return rowsAffected, err
+	}
+
+	//写入已完成, 所有 after 钩子都会执行, 如审计失败时仍需失效缓存
+	hc.RowsAffected = rowsAffected
+	errs := make([]error, 0)
+	for _, hook := range after {
+		if err = hook(hc); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return rowsAffected, errors.Join(errs...)
+}
+
+// loadAffected 按主键分批读取写操作命中的记录, 读主库; 写操作不受 WithTrashed/OnlyTrashed 影响, unscoped 时包括已软删除的记录
+func (r Repository[ModelType, PrimaryType]) loadAffected(conditions map[string]any, unscoped bool, fn func(entities []*ModelType) error) error {
+	repo := r.Primary()
+	repo.trashed = repositoryTrashedWithout
+	if unscoped {
+		repo = repo.WithTrashed()
+	}
+	if r.isCompositePrimaryKey() {
+		entities, err := repo.GetAllByConditions(conditions)
+		if err != nil {
+			return err
+		}
+		return fn(entities)
+	}
+
+	var fnErr error
+	if err := repo.Chunk(r.db.Statement.Context, conditions, repositoryHookChunkSize, func(entities []*ModelType) bool {
+		fnErr = fn(entities)
+		return fnErr == nil
+	}); err != nil {
+		return err
+	}
+	return fnErr
+}
@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+)
+
+// IRepository Repository 与 RepositoryMemory 共同实现的接口, 便于业务代码脱离数据库进行单元测试
+type IRepository[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey] interface {
+	Create(entities ...*ModelType) error
+	Save(entity *ModelType, fields ...string) error
+
+	Update(id PrimaryType, field string, value any) error
+	Updates(id PrimaryType, params map[string]any) error
+	UpdatesIn(ids []PrimaryType, params map[string]any) error
+	UpdatesByConditions(conditions map[string]any, params map[string]any) error
+
+	Delete(id PrimaryType) error
+	DeleteIn(ids []PrimaryType) error
+	DeleteByField(field string, value any) error
+	DeleteByConditions(conditions map[string]any) error
+
+	Get(id PrimaryType, preloads ...string) (*ModelType, error)
+	GetIn(values []PrimaryType, preloads ...string) ([]*ModelType, error)
+	GetByField(field string, value any, preloads ...string) (*ModelType, error)
+	GetByConditions(conditions map[string]any, preloads ...string) (*ModelType, error)
+	GetFirstByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error)
+	GetAll(preloads ...string) ([]*ModelType, error)
+	GetAllByField(field string, value any, preloads ...string) ([]*ModelType, error)
+	GetAllByConditions(conditions map[string]any, preloads ...string) ([]*ModelType, error)
+	GetAllByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) ([]*ModelType, error)
+
+	CountByField(field string, value any) (int64, error)
+	CountByConditions(conditions map[string]any) (int64, error)
+
+	FindPage(query RepositoryPageQuery) ([]*ModelType, int64, error)
+	TableName() string
+}
+
+// RepositoryPageQuery 分页查询参数, Sorts 的列名应已校验
+type RepositoryPageQuery struct {
+	Conditions map[string]any
+	Scope      func(db *gorm.DB) *gorm.DB
+	Sorts      []PaginationSort
+	Preloads   []string
+	Limit      int
+	Offset     int
+}
+
+var (
+	_ IRepository[repositoryInterfaceModel, int64] = (*Repository[repositoryInterfaceModel, int64])(nil)
+	_ IRepository[repositoryInterfaceModel, int64] = (*RepositoryMemory[repositoryInterfaceModel, int64])(nil)
+)
+
+type repositoryInterfaceModel struct{}
+
+func (repositoryInterfaceModel) PrimaryKey() string { return "id" }
+
+// FindPage 返回当前页记录及满足条件的总数
+func (r Repository[ModelType, PrimaryType]) FindPage(query RepositoryPageQuery) ([]*ModelType, int64, error) {
+	var (
+		err      error
+		count    int64
+		entities []*ModelType
+		builder  = r.queryDB()
+	)
+
+	//构造条件
+	if query.Scope != nil {
+		builder = query.Scope(builder)
+	}
+	builder = r.buildConditions(builder, query.Conditions)
+
+	//查询总数
+	if err = builder.Model(&r.model).Count(&count).Error; err != nil {
+		return nil, 0, err
+	}
+
+	builder = r.buildPreloads(builder, query.Preloads...)
+	for _, v := range query.Sorts {
+		column, err := r.resolveColumn(v.Column)
+		if err != nil {
+			return nil, 0, err
+		}
+		builder = builder.Order(v.orderByColumn(column))
+	}
+	if query.Limit > 0 {
+		builder = builder.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		builder = builder.Offset(query.Offset)
+	}
+	if err = builder.Find(&entities).Error; err != nil {
+		return nil, 0, err
+	}
+	return entities, count, nil
+}
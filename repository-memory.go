@@ -0,0 +1,608 @@
+package utils
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// RepositoryMemory 内存版 IRepository, 用于单元测试
+// 条件语义与 Repository 一致: 等值、切片为 IN、nil 为 IS NULL; 不支持 clause.Expression 条件、preloads 与软删除
+type RepositoryMemory[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey] struct {
+	mux    sync.RWMutex
+	model  ModelType
+	rows   map[PrimaryType]*ModelType
+	order  []PrimaryType
+	nextID int64
+}
+
+func NewRepositoryMemory[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey]() *RepositoryMemory[ModelType, PrimaryType] {
+	return &RepositoryMemory[ModelType, PrimaryType]{
+		rows:  make(map[PrimaryType]*ModelType),
+		order: make([]PrimaryType, 0),
+	}
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Create(entities ...*ModelType) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.create(entities...)
+}
+
+// create 调用方需持有写锁
+func (r *RepositoryMemory[ModelType, PrimaryType]) create(entities ...*ModelType) error {
+	for _, entity := range entities {
+		id, err := r.assignPrimaryKey(entity)
+		if err != nil {
+			return err
+		}
+		if _, ok := r.rows[id]; ok {
+			return fmt.Errorf("duplicated primary key: %v", id)
+		}
+		r.rows[id] = r.clone(entity)
+		r.order = append(r.order, id)
+	}
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Save(entity *ModelType, fields ...string) error {
+	id, zero, err := r.primaryKeyOf(entity)
+	if err != nil {
+		return err
+	}
+
+	var params map[string]any
+	if len(fields) > 0 {
+		sch, err := r.parseSchema()
+		if err != nil {
+			return err
+		}
+		params = make(map[string]any, len(fields))
+		for _, v := range fields {
+			field := repositoryLookUpField(sch, v)
+			if field == nil {
+				return fmt.Errorf("unknown column: %s", v)
+			}
+			params[field.DBName], _ = field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+		}
+	}
+
+	//存在性检查与写入在同一把写锁内完成
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	stored, ok := r.rows[id]
+	if zero || !ok {
+		return r.create(entity)
+	}
+	if len(fields) == 0 {
+		r.rows[id] = r.clone(entity)
+		return nil
+	}
+	return r.apply(stored, params)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Update(id PrimaryType, field string, value any) error {
+	return r.Updates(id, map[string]any{field: value})
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Updates(id PrimaryType, params map[string]any) error {
+	return r.UpdatesIn([]PrimaryType{id}, params)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) UpdatesIn(ids []PrimaryType, params map[string]any) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, id := range ids {
+		if entity, ok := r.rows[id]; ok {
+			if err := r.apply(entity, params); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) UpdatesByConditions(conditions map[string]any, params map[string]any) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ids, err := r.filter(conditions)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = r.apply(r.rows[id], params); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Delete(id PrimaryType) error {
+	return r.DeleteIn([]PrimaryType{id})
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) DeleteIn(ids []PrimaryType) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.remove(ids)
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) DeleteByField(field string, value any) error {
+	return r.DeleteByConditions(map[string]any{field: value})
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) DeleteByConditions(conditions map[string]any) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	ids, err := r.filter(conditions)
+	if err != nil {
+		return err
+	}
+	r.remove(ids)
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) Get(id PrimaryType, preloads ...string) (*ModelType, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	if entity, ok := r.rows[id]; ok {
+		return r.clone(entity), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetIn(values []PrimaryType, preloads ...string) ([]*ModelType, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	result := make([]*ModelType, 0, len(values))
+	for _, id := range r.order {
+		for _, v := range values {
+			if v == id {
+				result = append(result, r.clone(r.rows[id]))
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetByField(field string, value any, preloads ...string) (*ModelType, error) {
+	return r.GetByConditions(map[string]any{field: value}, preloads...)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetByConditions(conditions map[string]any, preloads ...string) (*ModelType, error) {
+	return r.GetFirstByConditionsOrderByLimitOffset(conditions, "", -1, -1, preloads...)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetFirstByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error) {
+	entities, err := r.GetAllByConditionsOrderByLimitOffset(conditions, orderBy, limit, offset, preloads...)
+	if err != nil {
+		return nil, err
+	}
+	if len(entities) == 0 {
+		return nil, ErrNotFound
+	}
+	return entities[0], nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetAll(preloads ...string) ([]*ModelType, error) {
+	return r.GetAllByConditionsOrderByLimitOffset(nil, "", -1, -1, preloads...)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetAllByField(field string, value any, preloads ...string) ([]*ModelType, error) {
+	return r.GetAllByConditionsOrderByLimitOffset(map[string]any{field: value}, "", -1, -1, preloads...)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetAllByConditions(conditions map[string]any, preloads ...string) ([]*ModelType, error) {
+	return r.GetAllByConditionsOrderByLimitOffset(conditions, "", -1, -1, preloads...)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) GetAllByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) ([]*ModelType, error) {
+	sorts, err := r.parseOrderBy(orderBy)
+	if err != nil {
+		return nil, err
+	}
+	entities, _, err := r.find(conditions, sorts, limit, offset)
+	return entities, err
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) CountByField(field string, value any) (int64, error) {
+	return r.CountByConditions(map[string]any{field: value})
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) CountByConditions(conditions map[string]any) (int64, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	ids, err := r.filter(conditions)
+	return int64(len(ids)), err
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) FindPage(query RepositoryPageQuery) ([]*ModelType, int64, error) {
+	if query.Scope != nil {
+		return nil, 0, fmt.Errorf("scope is not supported by memory repository")
+	}
+	return r.find(query.Conditions, query.Sorts, query.Limit, query.Offset)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) TableName() string {
+	return Repository[ModelType, PrimaryType]{}.TableName()
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) find(conditions map[string]any, sorts []PaginationSort, limit, offset int) ([]*ModelType, int64, error) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	ids, err := r.filter(conditions)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(sorts) > 0 {
+		sch, err := r.parseSchema()
+		if err != nil {
+			return nil, 0, err
+		}
+		fields := make([]*schema.Field, 0, len(sorts))
+		for _, v := range sorts {
+			column := v.Column
+			if i := strings.LastIndex(column, "."); i >= 0 {
+				column = column[i+1:]
+			}
+			field := repositoryLookUpField(sch, column)
+			if field == nil {
+				return nil, 0, fmt.Errorf("unknown sort column: %s", v.Column)
+			}
+			fields = append(fields, field)
+		}
+		sort.SliceStable(ids, func(i, j int) bool {
+			for k, field := range fields {
+				c := repositoryMemoryCompare(r.columnValue(field, r.rows[ids[i]]), r.columnValue(field, r.rows[ids[j]]))
+				if c == 0 {
+					continue
+				}
+				if sorts[k].Descending {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	total := int64(len(ids))
+	if offset > 0 {
+		if offset > len(ids) {
+			offset = len(ids)
+		}
+		ids = ids[offset:]
+	}
+	if limit > 0 && limit < len(ids) {
+		ids = ids[:limit]
+	}
+
+	result := make([]*ModelType, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, r.clone(r.rows[id]))
+	}
+	return result, total, nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) filter(conditions map[string]any) ([]PrimaryType, error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]*schema.Field, len(conditions))
+	for k, v := range conditions {
+		if _, ok := v.(clause.Expression); ok {
+			return nil, fmt.Errorf("condition %s: clause.Expression is not supported by memory repository", k)
+		}
+		if fields[k] = repositoryLookUpField(sch, k); fields[k] == nil {
+			return nil, fmt.Errorf("unknown column: %s", k)
+		}
+	}
+
+	ids := make([]PrimaryType, 0)
+	for _, id := range r.order {
+		matched := true
+		for k, v := range conditions {
+			if !repositoryMemoryMatch(r.columnValue(fields[k], r.rows[id]), v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) remove(ids []PrimaryType) {
+	removed := make(map[PrimaryType]struct{}, len(ids))
+	for _, id := range ids {
+		if _, ok := r.rows[id]; ok {
+			delete(r.rows, id)
+			removed[id] = struct{}{}
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	order := make([]PrimaryType, 0, len(r.order))
+	for _, id := range r.order {
+		if _, ok := removed[id]; !ok {
+			order = append(order, id)
+		}
+	}
+	r.order = order
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) apply(entity *ModelType, params map[string]any) error {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return err
+	}
+	rValue := reflect.ValueOf(entity).Elem()
+	for k, v := range params {
+		field := repositoryLookUpField(sch, k)
+		if field == nil {
+			return fmt.Errorf("unknown column: %s", k)
+		}
+		if field.PrimaryKey {
+			return fmt.Errorf("primary key %s can not be updated", k)
+		}
+		if err = field.Set(context.Background(), rValue, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) columnValue(field *schema.Field, entity *ModelType) any {
+	value, _ := field.ValueOf(context.Background(), reflect.ValueOf(entity).Elem())
+	return repositoryMemoryNormalize(value)
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) primaryKeyOf(entity *ModelType) (id PrimaryType, zero bool, err error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return
+	}
+	field := sch.LookUpField(r.model.PrimaryKey())
+	if field == nil {
+		return id, false, fmt.Errorf("primary key %s not found in %s", r.model.PrimaryKey(), sch.Name)
+	}
+	value := reflect.Indirect(field.ReflectValueOf(context.Background(), reflect.ValueOf(entity).Elem()))
+	if !value.Type().ConvertibleTo(reflect.TypeOf(id)) {
+		return id, false, fmt.Errorf("primary key %s is not of type %T", r.model.PrimaryKey(), id)
+	}
+	return value.Convert(reflect.TypeOf(id)).Interface().(PrimaryType), value.IsZero(), nil
+}
+
+// assignPrimaryKey 整数主键为零值时模拟自增
+func (r *RepositoryMemory[ModelType, PrimaryType]) assignPrimaryKey(entity *ModelType) (PrimaryType, error) {
+	id, zero, err := r.primaryKeyOf(entity)
+	if err != nil || !zero {
+		if err == nil {
+			switch v := repositoryMemoryNormalize(id).(type) {
+			case int64:
+				if v > r.nextID {
+					r.nextID = v
+				}
+			case uint64:
+				if v <= math.MaxInt64 && int64(v) > r.nextID {
+					r.nextID = int64(v)
+				}
+			}
+		}
+		return id, err
+	}
+
+	sch, _ := r.parseSchema()
+	field := sch.LookUpField(r.model.PrimaryKey())
+	switch reflect.Indirect(reflect.ValueOf(id)).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		r.nextID++
+		if err = field.Set(context.Background(), reflect.ValueOf(entity).Elem(), r.nextID); err != nil {
+			return id, err
+		}
+		id, _, err = r.primaryKeyOf(entity)
+		return id, err
+	default:
+		return id, fmt.Errorf("primary key %s is required", r.model.PrimaryKey())
+	}
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) parseSchema() (*schema.Schema, error) {
+	return schema.Parse(&r.model, repositoryMemorySchemaCache, schema.NamingStrategy{})
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) parseOrderBy(orderBy string) ([]PaginationSort, error) {
+	sorts := make([]PaginationSort, 0)
+	for _, v := range strings.Split(orderBy, ",") {
+		parts := strings.Fields(strings.NewReplacer("`", "", "\"", "").Replace(v))
+		switch {
+		case len(parts) == 0:
+			continue
+		case len(parts) == 1:
+			sorts = append(sorts, PaginationSort{Column: parts[0]})
+		case len(parts) == 2 && (strings.EqualFold(parts[1], "asc") || strings.EqualFold(parts[1], "desc")):
+			sorts = append(sorts, PaginationSort{Column: parts[0], Descending: strings.EqualFold(parts[1], "desc")})
+		default:
+			return nil, fmt.Errorf("unsupported order by: %s", v)
+		}
+	}
+	return sorts, nil
+}
+
+func (r *RepositoryMemory[ModelType, PrimaryType]) clone(entity *ModelType) *ModelType {
+	c := *entity
+	return &c
+}
+
+var repositoryMemorySchemaCache = &sync.Map{}
+
+func repositoryMemoryMatch(value, condition any) bool {
+	if valuer, ok := condition.(driver.Valuer); ok {
+		condition, _ = valuer.Value()
+	}
+	if condition == nil {
+		return value == nil
+	}
+	rCondition := reflect.ValueOf(condition)
+	if (rCondition.Kind() == reflect.Slice || rCondition.Kind() == reflect.Array) && rCondition.Type().Elem().Kind() != reflect.Uint8 {
+		for _, v := range SliceToAnySlice(condition) {
+			if repositoryMemoryMatch(value, v) {
+				return true
+			}
+		}
+		return false
+	}
+	if value == nil {
+		return false
+	}
+	return repositoryMemoryCompare(value, repositoryMemoryNormalize(condition)) == 0
+}
+
+// repositoryMemoryNormalize 将 Valuer、指针及各数值类型统一为 int64/uint64/float64, 便于比较
+func repositoryMemoryNormalize(value any) any {
+	if valuer, ok := value.(driver.Valuer); ok {
+		value, _ = valuer.Value()
+	}
+	if value == nil {
+		return nil
+	}
+	rValue := reflect.ValueOf(value)
+	for rValue.Kind() == reflect.Ptr {
+		if rValue.IsNil() {
+			return nil
+		}
+		rValue = rValue.Elem()
+		if valuer, ok := rValue.Interface().(driver.Valuer); ok {
+			v, _ := valuer.Value()
+			return repositoryMemoryNormalize(v)
+		}
+	}
+	switch rValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rValue.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return rValue.Uint()
+	case reflect.Float32, reflect.Float64:
+		return rValue.Float()
+	case reflect.String:
+		return rValue.String()
+	case reflect.Bool:
+		return rValue.Bool()
+	}
+	if b, ok := rValue.Interface().([]byte); ok {
+		return string(b)
+	}
+	return rValue.Interface()
+}
+
+func repositoryMemoryCompare(a, b any) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	}
+	if c, ok := repositoryMemoryCompareNumber(a, b); ok {
+		return c
+	}
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(av, bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch {
+			case av == bv:
+				return 0
+			case !av:
+				return -1
+			}
+			return 1
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// repositoryMemoryCompareNumber 同类型整数按原生类型比较, 不同数值类型之间按精确值比较, 避免大整数转为 float64 时丢失精度
+func repositoryMemoryCompareNumber(a, b any) (int, bool) {
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			return repositoryMemoryCompareOrdered(av, bv), true
+		}
+	case uint64:
+		if bv, ok := b.(uint64); ok {
+			return repositoryMemoryCompareOrdered(av, bv), true
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			return repositoryMemoryCompareOrdered(av, bv), true
+		}
+	}
+	af, ok := repositoryMemoryBigFloat(a)
+	if !ok {
+		return 0, false
+	}
+	bf, ok := repositoryMemoryBigFloat(b)
+	if !ok {
+		return 0, false
+	}
+	return af.Cmp(bf), true
+}
+
+func repositoryMemoryCompareOrdered[T int64 | uint64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func repositoryMemoryBigFloat(value any) (*big.Float, bool) {
+	switch v := value.(type) {
+	case int64:
+		return new(big.Float).SetInt64(v), true
+	case uint64:
+		return new(big.Float).SetUint64(v), true
+	case float64:
+		if math.IsNaN(v) {
+			return nil, false
+		}
+		return new(big.Float).SetFloat64(v), true
+	}
+	return nil, false
+}
@@ -0,0 +1,155 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IRepositoryCompositeModel 复合主键模型, PrimaryKeys 返回的列顺序与主键类型的取值顺序一致
+type IRepositoryCompositeModel interface {
+	PrimaryKeys() []string
+}
+
+// IRepositoryCompositeKey 复合主键类型可实现该接口自定义取值, 否则按结构体导出字段的顺序取值
+type IRepositoryCompositeKey interface {
+	KeyValues() []any
+}
+
+func (r Repository[ModelType, PrimaryType]) primaryKeys() []string {
+	if m, ok := any(r.model).(IRepositoryCompositeModel); ok {
+		if keys := m.PrimaryKeys(); len(keys) > 0 {
+			return keys
+		}
+	}
+	return []string{r.model.PrimaryKey()}
+}
+
+func (r Repository[ModelType, PrimaryType]) isCompositePrimaryKey() bool {
+	return len(r.primaryKeys()) > 1
+}
+
+func (r Repository[ModelType, PrimaryType]) primaryValues(id PrimaryType) ([]any, error) {
+	keys := r.primaryKeys()
+	if len(keys) == 1 {
+		return []any{id}, nil
+	}
+	if k, ok := any(id).(IRepositoryCompositeKey); ok {
+		if values := k.KeyValues(); len(values) == len(keys) {
+			return values, nil
+		}
+		return nil, fmt.Errorf("composite key %T should have %d values", id, len(keys))
+	}
+
+	rValue := reflect.ValueOf(id)
+	if rValue.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("composite key %T should be a struct", id)
+	}
+	values := make([]any, 0, len(keys))
+	for i := 0; i < rValue.NumField(); i++ {
+		if rValue.Type().Field(i).IsExported() {
+			values = append(values, rValue.Field(i).Interface())
+		}
+	}
+	if len(values) != len(keys) {
+		return nil, fmt.Errorf("composite key %T should have %d fields", id, len(keys))
+	}
+	return values, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) primaryCondition(id PrimaryType) (clause.Expression, error) {
+	values, err := r.primaryValues(id)
+	if err != nil {
+		return nil, err
+	}
+	exprs := make([]clause.Expression, 0, len(values))
+	for i, key := range r.primaryKeys() {
+		exprs = append(exprs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: key}, Value: values[i]})
+	}
+	return clause.And(exprs...), nil
+}
+
+func (r Repository[ModelType, PrimaryType]) primaryInCondition(ids []PrimaryType) (clause.Expression, error) {
+	if !r.isCompositePrimaryKey() {
+		return clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: r.model.PrimaryKey()}, Values: SliceToAnySlice(ids)}, nil
+	}
+	if len(ids) == 0 {
+		return clause.IN{Column: clause.Column{Table: clause.CurrentTable, Name: r.primaryKeys()[0]}}, nil
+	}
+	exprs := make([]clause.Expression, 0, len(ids))
+	for _, id := range ids {
+		expr, err := r.primaryCondition(id)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return clause.Or(exprs...), nil
+}
+
+func (r Repository[ModelType, PrimaryType]) wherePrimary(builder *gorm.DB, id PrimaryType) *gorm.DB {
+	expr, err := r.primaryCondition(id)
+	if err != nil {
+		builder = builder.Session(&gorm.Session{})
+		_ = builder.AddError(err)
+		return builder
+	}
+	return builder.Where(expr)
+}
+
+func (r Repository[ModelType, PrimaryType]) wherePrimaryIn(builder *gorm.DB, ids []PrimaryType) *gorm.DB {
+	expr, err := r.primaryInCondition(ids)
+	if err != nil {
+		builder = builder.Session(&gorm.Session{})
+		_ = builder.AddError(err)
+		return builder
+	}
+	return builder.Where(expr)
+}
+
+// primaryConditions 构造钩子使用的条件, 单主键为 {主键: id}, 复合主键为 {列: 值, ...}
+func (r Repository[ModelType, PrimaryType]) primaryConditions(id PrimaryType) map[string]any {
+	keys := r.primaryKeys()
+	values, err := r.primaryValues(id)
+	if err != nil {
+		return map[string]any{keys[0]: id}
+	}
+	conditions := make(map[string]any, len(keys))
+	for i, key := range keys {
+		conditions[key] = values[i]
+	}
+	return conditions
+}
+
+// primaryInConditions 构造钩子使用的条件, 单主键为 {主键: ids}, 复合主键以 clause.Expression 表示
+func (r Repository[ModelType, PrimaryType]) primaryInConditions(ids []PrimaryType) map[string]any {
+	if !r.isCompositePrimaryKey() {
+		return map[string]any{r.model.PrimaryKey(): ids}
+	}
+	expr, err := r.primaryInCondition(ids)
+	if err != nil {
+		return map[string]any{r.primaryKeys()[0]: ids}
+	}
+	return map[string]any{"": expr}
+}
+
+// entityPrimaryConditions 返回实体主键列的条件, 所有主键列均为零值时 zero 为 true
+func (r Repository[ModelType, PrimaryType]) entityPrimaryConditions(entity *ModelType) (conditions map[string]any, zero bool, err error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return nil, false, err
+	}
+	zero, conditions = true, make(map[string]any)
+	for _, key := range r.primaryKeys() {
+		field := sch.LookUpField(key)
+		if field == nil {
+			return nil, false, fmt.Errorf("primary key %s not found in %s", key, sch.Name)
+		}
+		value, isZero := field.ValueOf(r.db.Statement.Context, reflect.ValueOf(entity).Elem())
+		conditions[field.DBName] = value
+		zero = zero && isZero
+	}
+	return conditions, zero, nil
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"gorm.io/gorm"
+)
+
+// IRepositoryReplicaPolicy 从只读副本中选择本次读操作使用的连接
+type IRepositoryReplicaPolicy interface {
+	Resolve(replicas []*gorm.DB) *gorm.DB
+}
+
+type RepositoryRandomPolicy struct{}
+
+func (RepositoryRandomPolicy) Resolve(replicas []*gorm.DB) *gorm.DB {
+	return replicas[rand.Intn(len(replicas))]
+}
+
+type RepositoryRoundRobinPolicy struct {
+	next uint64
+}
+
+func (p *RepositoryRoundRobinPolicy) Resolve(replicas []*gorm.DB) *gorm.DB {
+	return replicas[(atomic.AddUint64(&p.next, 1)-1)%uint64(len(replicas))]
+}
+
+// NewRepositoryWithReplicas 写操作使用 primary, Get*/Count*/Chunk/Pagination 等读操作按 policy 使用 replicas, policy 为空时随机选择
+func NewRepositoryWithReplicas[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](primary *gorm.DB, replicas []*gorm.DB, policy IRepositoryReplicaPolicy) *Repository[ModelType, PrimaryType] {
+	if policy == nil {
+		policy = RepositoryRandomPolicy{}
+	}
+	return &Repository[ModelType, PrimaryType]{
+		db:       primary,
+		replicas: replicas,
+		policy:   policy,
+		hooks:    newRepositoryHooks[ModelType](),
+	}
+}
+
+// Primary 返回读操作也使用主库的 Repository, 用于写后立即读取的场景
+func (r Repository[ModelType, PrimaryType]) Primary() *Repository[ModelType, PrimaryType] {
+	r.replicas = nil
+	return &r
+}
+
+func (r Repository[ModelType, PrimaryType]) readDB() *gorm.DB {
+	if len(r.replicas) == 0 || r.policy == nil {
+		return r.db
+	}
+	if db := r.policy.Resolve(r.replicas); db != nil {
+		return db
+	}
+	return r.db
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+type repositoryTrashed int
+
+const (
+	repositoryTrashedWithout repositoryTrashed = iota
+	repositoryTrashedWith
+	repositoryTrashedOnly
+)
+
+// WithTrashed 返回同时查询已软删除记录的 Repository, 仅影响 Get*/Count*/Chunk/Pagination 等读操作
+func (r Repository[ModelType, PrimaryType]) WithTrashed() *Repository[ModelType, PrimaryType] {
+	r.trashed = repositoryTrashedWith
+	return &r
+}
+
+// OnlyTrashed 返回仅查询已软删除记录的 Repository, 仅影响读操作
+func (r Repository[ModelType, PrimaryType]) OnlyTrashed() *Repository[ModelType, PrimaryType] {
+	r.trashed = repositoryTrashedOnly
+	return &r
+}
+
+func (r Repository[ModelType, PrimaryType]) Restore(ids ...PrimaryType) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	field, err := r.softDeleteField()
+	if err != nil {
+		return err
+	}
+	params := map[string]any{field.DBName: nil}
+	hc := &RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: r.primaryInConditions(ids), Params: params, Unscoped: true}
+	_, err = r.withHooks(hc, func() (int64, error) {
+		builder := r.wherePrimaryIn(r.writeDB().Unscoped().Omit(clause.Associations).Model(&r.model), ids).
+			Where(clause.Not(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}))
+		result := r.updatesSelected(builder, r.bumpVersion(params))
+		return result.RowsAffected, result.Error
+	})
+	return err
+}
+
+// ForceDelete 物理删除, 包括已软删除的记录
+func (r Repository[ModelType, PrimaryType]) ForceDelete(ids ...PrimaryType) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionDelete, Conditions: r.primaryInConditions(ids), Unscoped: true}, func() (int64, error) {
+		result := r.wherePrimaryIn(r.writeDB().Unscoped().Omit(clause.Associations), ids).Delete(&r.model)
+		return result.RowsAffected, result.Error
+	})
+	return err
+}
+
+func (r Repository[ModelType, PrimaryType]) queryDB() *gorm.DB {
+	db := r.scopeTenant(r.readDB())
+	switch r.trashed {
+	case repositoryTrashedWith:
+		return db.Unscoped()
+	case repositoryTrashedOnly:
+		field, err := r.softDeleteField()
+		if err != nil {
+			db = db.Session(&gorm.Session{})
+			_ = db.AddError(err)
+			return db
+		}
+		return db.Unscoped().Where(clause.Not(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: nil}))
+	default:
+		return db
+	}
+}
+
+func (r Repository[ModelType, PrimaryType]) softDeleteField() (*schema.Field, error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return nil, err
+	}
+	deletedAtType := reflect.TypeOf(gorm.DeletedAt{})
+	for _, field := range sch.Fields {
+		if field.DBName != "" && field.FieldType == deletedAtType {
+			return field, nil
+		}
+	}
+	return nil, fmt.Errorf("%s does not support soft delete", sch.Name)
+}
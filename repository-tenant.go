@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrCrossTenant = errors.New("repository: cross tenant write")
+
+// RepositoryTenant 多租户范围, Resolve 从 context 中取得当前租户, 失败时所有操作返回该错误
+type RepositoryTenant struct {
+	Column  string
+	Resolve func(ctx context.Context) (any, error)
+}
+
+// TenantFromContext 从 ctx.Value(key) 读取租户, 值不存在时返回错误
+func TenantFromContext(key any) func(ctx context.Context) (any, error) {
+	return func(ctx context.Context) (any, error) {
+		if ctx != nil {
+			if v := ctx.Value(key); v != nil {
+				return v, nil
+			}
+		}
+		return nil, fmt.Errorf("tenant not found in context: %v", key)
+	}
+}
+
+// WithTenant 返回限定租户范围的 Repository: 读/改/删/统计自动附加租户条件, 新增时自动写入租户列
+func (r Repository[ModelType, PrimaryType]) WithTenant(tenant RepositoryTenant) *Repository[ModelType, PrimaryType] {
+	r.tenant = &tenant
+	return &r
+}
+
+// WithoutTenant 返回不限定租户范围的 Repository, 用于后台管理等场景
+func (r Repository[ModelType, PrimaryType]) WithoutTenant() *Repository[ModelType, PrimaryType] {
+	r.tenant = nil
+	return &r
+}
+
+// WithContext 返回使用 ctx 的 Repository, 租户等从 ctx 中解析
+func (r Repository[ModelType, PrimaryType]) WithContext(ctx context.Context) *Repository[ModelType, PrimaryType] {
+	r.db = r.db.WithContext(ctx)
+	replicas := make([]*gorm.DB, 0, len(r.replicas))
+	for _, v := range r.replicas {
+		replicas = append(replicas, v.WithContext(ctx))
+	}
+	r.replicas = replicas
+	return &r
+}
+
+func (r Repository[ModelType, PrimaryType]) writeDB() *gorm.DB {
+	return r.scopeTenant(r.db)
+}
+
+func (r Repository[ModelType, PrimaryType]) scopeTenant(db *gorm.DB) *gorm.DB {
+	if r.tenant == nil {
+		return db
+	}
+	value, err := r.tenantValue()
+	if err != nil {
+		db = db.Session(&gorm.Session{})
+		_ = db.AddError(err)
+		return db
+	}
+	return db.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: r.tenant.Column}, Value: value})
+}
+
+func (r Repository[ModelType, PrimaryType]) tenantValue() (any, error) {
+	if r.tenant.Resolve == nil {
+		return nil, fmt.Errorf("tenant resolver of %s is required", r.tenant.Column)
+	}
+	return r.tenant.Resolve(r.db.Statement.Context)
+}
+
+// guardTenant 写入租户列并拒绝跨租户写入
+func (r Repository[ModelType, PrimaryType]) guardTenant(hc *RepositoryHookContext[ModelType]) error {
+	if r.tenant == nil {
+		return nil
+	}
+	value, err := r.tenantValue()
+	if err != nil {
+		return err
+	}
+
+	if v, ok := hc.Params[r.tenant.Column]; ok && fmt.Sprint(v) != fmt.Sprint(value) {
+		return ErrCrossTenant
+	}
+	if len(hc.Entities) == 0 {
+		return nil
+	}
+
+	sch, err := r.parseSchema()
+	if err != nil {
+		return err
+	}
+	field := sch.LookUpField(r.tenant.Column)
+	if field == nil {
+		return fmt.Errorf("tenant column %s not found in %s", r.tenant.Column, sch.Name)
+	}
+	ctx := r.db.Statement.Context
+	for _, entity := range hc.Entities {
+		rValue := reflect.ValueOf(entity).Elem()
+		if current, zero := field.ValueOf(ctx, rValue); zero {
+			if err = field.Set(ctx, rValue, value); err != nil {
+				return err
+			}
+		} else if fmt.Sprint(current) != fmt.Sprint(value) {
+			return ErrCrossTenant
+		}
+	}
+
+	//Save 已有主键时, 记录不在本租户内但存在于其他租户则拒绝, 避免被 upsert 覆盖
+	if hc.Action == RepositoryActionUpdate && hc.Conditions != nil {
+		var count int64
+		if count, err = r.Primary().CountByConditions(hc.Conditions); err != nil || count > 0 {
+			return err
+		}
+		if count, err = r.Primary().WithoutTenant().WithTrashed().CountByConditions(hc.Conditions); err != nil {
+			return err
+		} else if count > 0 {
+			return ErrCrossTenant
+		}
+	}
+	return nil
+}
+
+// guardUpsertTenant 按冲突列(默认为主键)检查记录是否属于其他租户, 冲突列包含租户列时无需检查
+func (r Repository[ModelType, PrimaryType]) guardUpsertTenant(entities []*ModelType, conflictColumns []string) error {
+	if len(conflictColumns) == 0 {
+		conflictColumns = r.primaryKeys()
+	}
+	for _, v := range conflictColumns {
+		if v == r.tenant.Column {
+			return nil
+		}
+	}
+
+	value, err := r.tenantValue()
+	if err != nil {
+		return err
+	}
+	conflict, err := r.conflictCondition(entities, conflictColumns)
+	if err != nil || conflict == nil {
+		return err
+	}
+	condition := clause.And(
+		conflict,
+		clause.Neq{Column: clause.Column{Table: clause.CurrentTable, Name: r.tenant.Column}, Value: value},
+	)
+	count, err := r.Primary().WithoutTenant().WithTrashed().CountByConditions(map[string]any{"": condition})
+	if err != nil {
+		return err
+	} else if count > 0 {
+		return ErrCrossTenant
+	}
+	return nil
+}
+
+// tenantUpsertColumns 从更新列中去掉租户列, 未指定更新列时使用除主键、创建时间及租户列外的所有列
+func (r Repository[ModelType, PrimaryType]) tenantUpsertColumns(updateColumns []string) ([]string, error) {
+	columns := make([]string, 0, len(updateColumns))
+	if len(updateColumns) > 0 {
+		for _, v := range updateColumns {
+			if v != r.tenant.Column {
+				columns = append(columns, v)
+			}
+		}
+		if len(columns) == 0 {
+			return nil, fmt.Errorf("tenant column %s can not be upserted", r.tenant.Column)
+		}
+		return columns, nil
+	}
+
+	sch, err := r.parseSchema()
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range sch.Fields {
+		if field.DBName == "" || field.PrimaryKey || field.AutoCreateTime > 0 || !field.Creatable || !field.Updatable || field.DBName == r.tenant.Column {
+			continue
+		}
+		columns = append(columns, field.DBName)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns of %s to upsert", sch.Name)
+	}
+	return columns, nil
+}
@@ -0,0 +1,168 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+var ErrStaleEntity = errors.New("stale entity: version mismatch")
+
+// IRepositoryVersionModel 实现该接口的模型在 Save/Updates 时启用乐观锁, 版本列需为整数类型;
+// Updates 须在 params 中给出期望的当前版本, 其他更新方法不校验版本但同样自增版本
+type IRepositoryVersionModel interface {
+	VersionColumn() string
+}
+
+func (r Repository[ModelType, PrimaryType]) versionColumn() string {
+	if v, ok := any(r.model).(IRepositoryVersionModel); ok {
+		return v.VersionColumn()
+	}
+	return ""
+}
+
+// saveWithVersion 主键为零值或记录不存在时插入, 否则按当前版本更新并自增版本
+func (r Repository[ModelType, PrimaryType]) saveWithVersion(entity *ModelType, fields ...string) (int64, error) {
+	sch, err := r.parseSchema()
+	if err != nil {
+		return 0, err
+	}
+	versionField := sch.LookUpField(r.versionColumn())
+	if versionField == nil {
+		return 0, fmt.Errorf("version column %s not found in %s", r.versionColumn(), sch.Name)
+	}
+
+	ctx := r.db.Statement.Context
+	rValue := reflect.ValueOf(entity).Elem()
+	current, err := repositoryVersionOf(ctx, versionField, rValue)
+	if err != nil {
+		return 0, err
+	}
+	create := func() (int64, error) {
+		if current == 0 {
+			if err := versionField.Set(ctx, rValue, 1); err != nil {
+				return 0, err
+			}
+		}
+		result := r.writeDB().Omit(clause.Associations).Create(entity)
+		if result.Error != nil {
+			_ = versionField.Set(ctx, rValue, current)
+		}
+		return result.RowsAffected, result.Error
+	}
+
+	conditions, zero, err := r.entityPrimaryConditions(entity)
+	if err != nil {
+		return 0, err
+	}
+	if zero {
+		return create()
+	}
+
+	if err = versionField.Set(ctx, rValue, current+1); err != nil {
+		return 0, err
+	}
+	builder := r.writeDB().Omit(clause.Associations).Model(entity).Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: versionField.DBName}, Value: current})
+	if len(fields) > 0 {
+		builder = builder.Select(versionField.DBName, SliceToAnySlice(fields)...)
+	} else {
+		builder = builder.Select("*")
+	}
+	result := builder.Updates(entity)
+	if result.Error == nil && result.RowsAffected > 0 {
+		return result.RowsAffected, nil
+	}
+	_ = versionField.Set(ctx, rValue, current)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+
+	//未命中时记录存在为版本不一致, 不存在(如客户端生成的主键)则插入
+	count, err := r.Primary().CountByConditions(conditions)
+	if err != nil {
+		return 0, err
+	} else if count > 0 {
+		return 0, ErrStaleEntity
+	}
+	return create()
+}
+
+// updatesWithVersion params 中的版本列为期望的当前版本, 版本一致时更新并自增版本
+func (r Repository[ModelType, PrimaryType]) updatesWithVersion(builder *gorm.DB, params map[string]any) *gorm.DB {
+	column := r.versionColumn()
+	builder = builder.Where(clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: column}, Value: params[column]})
+	values := make(map[string]any, len(params))
+	for k, v := range params {
+		if k != column {
+			values[k] = v
+		}
+	}
+	return r.updatesSelected(builder, r.bumpVersion(values))
+}
+
+// requireVersion 带乐观锁的单条更新必须在 params 中给出期望的当前版本
+func (r Repository[ModelType, PrimaryType]) requireVersion(params map[string]any) error {
+	if column := r.versionColumn(); column != "" {
+		if _, ok := params[column]; !ok {
+			return fmt.Errorf("expected version %s is required", column)
+		}
+	}
+	return nil
+}
+
+// forbidVersion 版本列由 Repository 维护, 批量更新不允许直接写入
+func (r Repository[ModelType, PrimaryType]) forbidVersion(params map[string]any) error {
+	if column := r.versionColumn(); column != "" {
+		if _, ok := params[column]; ok {
+			return fmt.Errorf("version column %s can not be updated directly", column)
+		}
+	}
+	return nil
+}
+
+// bumpVersion 复制 params 并附加版本自增, 未启用乐观锁时原样返回
+func (r Repository[ModelType, PrimaryType]) bumpVersion(params map[string]any) map[string]any {
+	column := r.versionColumn()
+	if column == "" {
+		return params
+	}
+	values := make(map[string]any, len(params)+1)
+	for k, v := range params {
+		values[k] = v
+	}
+	values[column] = gorm.Expr("? + 1", clause.Column{Table: clause.CurrentTable, Name: column})
+	return values
+}
+
+// staleOrNotFound 乐观锁更新未命中时区分记录不存在与版本不一致
+func (r Repository[ModelType, PrimaryType]) staleOrNotFound(conditions map[string]any) (int64, error) {
+	count, err := r.Primary().CountByConditions(conditions)
+	if err != nil {
+		return 0, err
+	} else if count > 0 {
+		return 0, ErrStaleEntity
+	}
+	if r.strict {
+		return 0, ErrNotFound
+	}
+	return 0, nil
+}
+
+func repositoryVersionOf(ctx context.Context, field *schema.Field, rValue reflect.Value) (int64, error) {
+	value := reflect.Indirect(field.ReflectValueOf(ctx, rValue))
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(value.Uint()), nil
+	case reflect.Invalid:
+		return 0, nil
+	default:
+		return 0, fmt.Errorf("unsupported version type: %s", field.FieldType)
+	}
+}
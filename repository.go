@@ -2,6 +2,7 @@ package utils
 
 import (
 	"database/sql/driver"
+	"errors"
 	"fmt"
 	"reflect"
 
@@ -10,135 +11,362 @@ import (
 	"gorm.io/gorm/schema"
 )
 
+// ErrNotFound 包装了 gorm.ErrRecordNotFound, errors.Is 对两者均成立
+var ErrNotFound = fmt.Errorf("repository: %w", gorm.ErrRecordNotFound)
+
 type IRepositoryModel interface {
 	any
 	PrimaryKey() string
 }
+
+// iRepositoryPrimaryKey 支持整数、字符串、[16]byte 等 UUID 类型以及复合主键结构体, 见 IRepositoryCompositeModel
 type iRepositoryPrimaryKey interface {
-	string | int | int64
+	comparable
 }
 
 type Repository[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey] struct {
-	db    *gorm.DB
-	model ModelType
+	db      *gorm.DB
+	model   ModelType
+	trashed repositoryTrashed
+	strict  bool
+
+	replicas []*gorm.DB
+	policy   IRepositoryReplicaPolicy
+
+	hooks  *repositoryHooks[ModelType]
+	tenant *RepositoryTenant
 }
 
 func NewRepository[ModelType IRepositoryModel, PrimaryType iRepositoryPrimaryKey](db *gorm.DB) *Repository[ModelType, PrimaryType] {
 	return &Repository[ModelType, PrimaryType]{
-		db: db,
+		db:    db,
+		hooks: newRepositoryHooks[ModelType](),
 	}
 }
 
 func (r Repository[ModelType, PrimaryType]) Create(entities ...*ModelType) (err error) {
 	if len(entities) > 0 {
-		err = r.db.Omit(clause.Associations).Create(entities).Error
+		_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionCreate, Entities: entities}, func() (int64, error) {
+			result := r.writeDB().Omit(clause.Associations).Create(entities)
+			return result.RowsAffected, result.Error
+		})
+	}
+	return
+}
+
+func (r Repository[ModelType, PrimaryType]) CreateInBatches(entities []*ModelType, batchSize int) (err error) {
+	if len(entities) > 0 {
+		if batchSize <= 0 {
+			batchSize = len(entities)
+		}
+		_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionCreate, Entities: entities}, func() (int64, error) {
+			result := r.writeDB().Omit(clause.Associations).CreateInBatches(entities, batchSize)
+			return result.RowsAffected, result.Error
+		})
 	}
 	return
 }
 
+// CreateIgnore 忽略唯一键冲突的记录(MySQL 为 ON DUPLICATE KEY UPDATE 主键=主键, SQLite/PostgreSQL 为 ON CONFLICT DO NOTHING)
+func (r Repository[ModelType, PrimaryType]) CreateIgnore(entities ...*ModelType) (err error) {
+	if len(entities) > 0 {
+		_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionCreate, Entities: entities}, func() (int64, error) {
+			result := r.writeDB().Omit(clause.Associations).Clauses(clause.OnConflict{DoNothing: true}).Create(entities)
+			return result.RowsAffected, result.Error
+		})
+	}
+	return
+}
+
+// Upsert 冲突时更新 updateColumns, updateColumns 为空时更新所有非主键列; conflictColumns 仅在 SQLite/PostgreSQL 下生效
+// 钩子的操作类型为 RepositoryActionUpsert
+// 限定租户时不更新租户列, 并拒绝冲突列(默认为主键)命中其他租户记录的写入; MySQL 下其他唯一索引的冲突无法检查
+func (r Repository[ModelType, PrimaryType]) Upsert(entities []*ModelType, conflictColumns []string, updateColumns []string) (err error) {
+	if len(entities) == 0 {
+		return
+	}
+	if conflictColumns, err = r.resolveColumns(conflictColumns); err != nil {
+		return
+	}
+	if updateColumns, err = r.resolveColumns(updateColumns); err != nil {
+		return
+	}
+	if r.tenant != nil {
+		if err = r.guardUpsertTenant(entities, conflictColumns); err != nil {
+			return
+		}
+		if updateColumns, err = r.tenantUpsertColumns(updateColumns); err != nil {
+			return
+		}
+	}
+	onConflict := clause.OnConflict{}
+	for _, v := range conflictColumns {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: v})
+	}
+	if len(updateColumns) > 0 {
+		onConflict.DoUpdates = clause.AssignmentColumns(updateColumns)
+	} else {
+		onConflict.UpdateAll = true
+	}
+
+	hc := &RepositoryHookContext[ModelType]{Action: RepositoryActionUpsert, Entities: entities, Fields: updateColumns, ConflictColumns: conflictColumns}
+	if conflict, err := r.conflictCondition(entities, conflictColumns); err != nil {
+		return err
+	} else if conflict != nil {
+		hc.Conditions = map[string]any{"": conflict}
+	}
+	_, err = r.withHooks(hc, func() (int64, error) {
+		result := r.writeDB().Omit(clause.Associations).Clauses(onConflict).Create(entities)
+		return result.RowsAffected, result.Error
+	})
+	return
+}
+
+// conflictCondition 按冲突列(默认为主键)构造可能冲突的记录条件, 冲突列均为零值的实体不参与, 没有可冲突的实体时返回 nil
+func (r Repository[ModelType, PrimaryType]) conflictCondition(entities []*ModelType, conflictColumns []string) (clause.Expression, error) {
+	if len(conflictColumns) == 0 {
+		conflictColumns = r.primaryKeys()
+	}
+	sch, err := r.parseSchema()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([]*schema.Field, 0, len(conflictColumns))
+	for _, v := range conflictColumns {
+		field := sch.LookUpField(v)
+		if field == nil {
+			return nil, fmt.Errorf("conflict column %s not found in %s", v, sch.Name)
+		}
+		fields = append(fields, field)
+	}
+
+	ctx := r.db.Statement.Context
+	exprs := make([]clause.Expression, 0, len(entities))
+	for _, entity := range entities {
+		var (
+			rValue = reflect.ValueOf(entity).Elem()
+			eqs    = make([]clause.Expression, 0, len(fields))
+			zero   = true
+		)
+		for _, field := range fields {
+			v, isZero := field.ValueOf(ctx, rValue)
+			eqs = append(eqs, clause.Eq{Column: clause.Column{Table: clause.CurrentTable, Name: field.DBName}, Value: v})
+			zero = zero && isZero
+		}
+		if !zero {
+			exprs = append(exprs, clause.And(eqs...))
+		}
+	}
+	switch len(exprs) {
+	case 0:
+		return nil, nil
+	case 1:
+		//clause.Or 仅含一个条件时会被当作 OR 拼接到前一条件之后
+		return exprs[0], nil
+	default:
+		return clause.Or(exprs...), nil
+	}
+}
+
 func (r Repository[ModelType, PrimaryType]) Delete(id PrimaryType) error {
-	return r.db.Omit(clause.Associations).Delete(&r.model, id).Error
+	_, err := r.DeleteAffected(id)
+	return err
+}
+
+func (r Repository[ModelType, PrimaryType]) DeleteAffected(id PrimaryType) (int64, error) {
+	return r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionDelete, Conditions: r.primaryConditions(id)}, func() (int64, error) {
+		return r.checkAffected(r.wherePrimary(r.writeDB().Omit(clause.Associations), id).Delete(&r.model))
+	})
 }
 
 func (r Repository[ModelType, PrimaryType]) DeleteIn(ids []PrimaryType) error {
-	return r.db.Omit(clause.Associations).Delete(&r.model, ids).Error
+	_, err := r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionDelete, Conditions: r.primaryInConditions(ids)}, func() (int64, error) {
+		result := r.wherePrimaryIn(r.writeDB().Omit(clause.Associations), ids).Delete(&r.model)
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) DeleteByField(field string, value any) error {
-	builder := r.db.Omit(clause.Associations)
-	return r.buildWhereCondition(builder, field, value).Delete(&r.model).Error
+	return r.DeleteByConditions(map[string]any{field: value})
 }
 
 func (r Repository[ModelType, PrimaryType]) DeleteByConditions(conditions map[string]any) error {
-	builder := r.db.Omit(clause.Associations)
-	for k, v := range conditions {
-		builder = r.buildWhereCondition(builder, k, v)
-	}
-	return builder.Delete(&r.model).Error
+	_, err := r.DeleteByConditionsAffected(conditions)
+	return err
+}
+
+func (r Repository[ModelType, PrimaryType]) DeleteByConditionsAffected(conditions map[string]any) (int64, error) {
+	return r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionDelete, Conditions: conditions}, func() (int64, error) {
+		builder := r.writeDB().Omit(clause.Associations)
+		for k, v := range conditions {
+			builder = r.buildWhereCondition(builder, k, v)
+		}
+		result := builder.Delete(&r.model)
+		return result.RowsAffected, result.Error
+	})
 }
 
 func (r Repository[ModelType, PrimaryType]) DeleteAll() error {
-	builder := r.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Omit(clause.Associations)
-	return builder.Delete(&r.model).Error
+	_, err := r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionDelete, Conditions: map[string]any{}}, func() (int64, error) {
+		builder := r.writeDB().Session(&gorm.Session{AllowGlobalUpdate: true}).Omit(clause.Associations)
+		result := builder.Delete(&r.model)
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) Save(entity *ModelType, fields ...string) error {
-	return r.db.Omit(clause.Associations).Select(fields).Save(entity).Error
+	hc := &RepositoryHookContext[ModelType]{Action: RepositoryActionCreate, Entities: []*ModelType{entity}}
+	if conditions, zero, err := r.entityPrimaryConditions(entity); err != nil {
+		return err
+	} else if !zero {
+		hc.Action, hc.Conditions, hc.Fields = RepositoryActionUpdate, conditions, fields
+	}
+	_, err := r.withHooks(hc, func() (int64, error) {
+		if r.versionColumn() != "" {
+			return r.saveWithVersion(entity, fields...)
+		}
+		result := r.writeDB().Omit(clause.Associations).Select(fields).Save(entity)
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) Update(id PrimaryType, field string, value any) error {
-	return r.db.Omit(clause.Associations).Model(&r.model).Where(fmt.Sprintf("`%s`=?", r.model.PrimaryKey()), id).Select(field).Update(field, value).Error
+	_, err := r.UpdateAffected(id, field, value)
+	return err
+}
+
+func (r Repository[ModelType, PrimaryType]) UpdateAffected(id PrimaryType, field string, value any) (int64, error) {
+	field, err := r.resolveColumn(field)
+	if err != nil {
+		return 0, err
+	}
+	params := map[string]any{field: value}
+	if err = r.forbidVersion(params); err != nil {
+		return 0, err
+	}
+	return r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: r.primaryConditions(id), Params: params}, func() (int64, error) {
+		builder := r.wherePrimary(r.writeDB().Omit(clause.Associations).Model(&r.model), id)
+		return r.checkAffected(r.updatesSelected(builder, r.bumpVersion(params)))
+	})
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdateIn(ids []PrimaryType, field string, value any) error {
-	return r.db.Omit(clause.Associations).Model(&r.model).Where(fmt.Sprintf("`%s` in ?", r.model.PrimaryKey()), ids).Select(field).Update(field, value).Error
+	field, err := r.resolveColumn(field)
+	if err != nil {
+		return err
+	}
+	params := map[string]any{field: value}
+	if err = r.forbidVersion(params); err != nil {
+		return err
+	}
+	_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: r.primaryInConditions(ids), Params: params}, func() (int64, error) {
+		builder := r.wherePrimaryIn(r.writeDB().Omit(clause.Associations).Model(&r.model), ids)
+		result := r.updatesSelected(builder, r.bumpVersion(params))
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) Updates(id PrimaryType, params map[string]any) error {
-	selectedFields := make([]any, 0, len(params))
-	for k := range params {
-		selectedFields = append(selectedFields, k)
-	}
+	_, err := r.UpdatesAffected(id, params)
+	return err
+}
 
-	builder := r.db.Omit(clause.Associations).Model(&r.model).Where(fmt.Sprintf("`%s`=?", r.model.PrimaryKey()), id)
-	if len(selectedFields) > 0 {
-		builder = builder.Select(selectedFields[0], selectedFields[1:]...)
+func (r Repository[ModelType, PrimaryType]) UpdatesAffected(id PrimaryType, params map[string]any) (int64, error) {
+	params, err := r.resolveParams(params)
+	if err != nil {
+		return 0, err
+	}
+	if err = r.requireVersion(params); err != nil {
+		return 0, err
 	}
-	return builder.Updates(params).Error
+	conditions := r.primaryConditions(id)
+	return r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: conditions, Params: params}, func() (int64, error) {
+		builder := r.wherePrimary(r.writeDB().Omit(clause.Associations).Model(&r.model), id)
+		if r.versionColumn() != "" {
+			result := r.updatesWithVersion(builder, params)
+			if result.Error == nil && result.RowsAffected == 0 {
+				return r.staleOrNotFound(conditions)
+			}
+			return result.RowsAffected, result.Error
+		}
+		return r.checkAffected(r.updatesSelected(builder, params))
+	})
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdatesIn(ids []PrimaryType, params map[string]any) error {
-	selectedFields := make([]any, 0, len(params))
-	for k := range params {
-		selectedFields = append(selectedFields, k)
+	params, err := r.resolveParams(params)
+	if err != nil {
+		return err
 	}
-
-	builder := r.db.Omit(clause.Associations).Model(&r.model).Where(fmt.Sprintf("`%s` in ?", r.model.PrimaryKey()), ids)
-	if len(selectedFields) > 0 {
-		builder = builder.Select(selectedFields[0], selectedFields[1:]...)
+	if err = r.forbidVersion(params); err != nil {
+		return err
 	}
-	return builder.Updates(params).Error
+	_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: r.primaryInConditions(ids), Params: params}, func() (int64, error) {
+		builder := r.wherePrimaryIn(r.writeDB().Omit(clause.Associations).Model(&r.model), ids)
+		result := r.updatesSelected(builder, r.bumpVersion(params))
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdateByConditions(conditions map[string]any, field string, value any) error {
-	builder := r.db.Omit(clause.Associations).Model(&r.model)
-	for k, v := range conditions {
-		builder = r.buildWhereCondition(builder, k, v)
+	field, err := r.resolveColumn(field)
+	if err != nil {
+		return err
 	}
-	return builder.Select(field).Update(field, value).Error
+	_, err = r.UpdatesByConditionsAffected(conditions, map[string]any{field: value})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdatesByConditions(conditions map[string]any, params map[string]any) error {
-	selectedFields := make([]any, 0, len(params))
-	for k := range params {
-		selectedFields = append(selectedFields, k)
-	}
+	_, err := r.UpdatesByConditionsAffected(conditions, params)
+	return err
+}
 
-	builder := r.db.Omit(clause.Associations).Model(&r.model)
-	for k, v := range conditions {
-		builder = r.buildWhereCondition(builder, k, v)
+func (r Repository[ModelType, PrimaryType]) UpdatesByConditionsAffected(conditions map[string]any, params map[string]any) (int64, error) {
+	params, err := r.resolveParams(params)
+	if err != nil {
+		return 0, err
 	}
-	if len(selectedFields) > 0 {
-		builder = builder.Select(selectedFields[0], selectedFields[1:]...)
+	if err = r.forbidVersion(params); err != nil {
+		return 0, err
 	}
-	return builder.Updates(params).Error
+	return r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: conditions, Params: params}, func() (int64, error) {
+		builder := r.writeDB().Omit(clause.Associations).Model(&r.model)
+		for k, v := range conditions {
+			builder = r.buildWhereCondition(builder, k, v)
+		}
+		result := r.updatesSelected(builder, r.bumpVersion(params))
+		return result.RowsAffected, result.Error
+	})
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdateAll(field string, value any) error {
-	return r.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Omit(clause.Associations).Model(&r.model).Update(field, value).Error
+	field, err := r.resolveColumn(field)
+	if err != nil {
+		return err
+	}
+	return r.UpdatesAll(map[string]any{field: value})
 }
 
 func (r Repository[ModelType, PrimaryType]) UpdatesAll(params map[string]any) error {
-	selectedFields := make([]any, 0, len(params))
-	for k := range params {
-		selectedFields = append(selectedFields, k)
+	params, err := r.resolveParams(params)
+	if err != nil {
+		return err
 	}
-
-	builder := r.db.Session(&gorm.Session{AllowGlobalUpdate: true}).Omit(clause.Associations).Model(&r.model)
-	if len(selectedFields) > 0 {
-		builder = builder.Select(selectedFields[0], selectedFields[1:]...)
+	if err = r.forbidVersion(params); err != nil {
+		return err
 	}
-	return builder.Updates(params).Error
+	_, err = r.withHooks(&RepositoryHookContext[ModelType]{Action: RepositoryActionUpdate, Conditions: map[string]any{}, Params: params}, func() (int64, error) {
+		builder := r.writeDB().Session(&gorm.Session{AllowGlobalUpdate: true}).Omit(clause.Associations).Model(&r.model)
+		result := r.updatesSelected(builder, r.bumpVersion(params))
+		return result.RowsAffected, result.Error
+	})
+	return err
 }
 
 func (r Repository[ModelType, PrimaryType]) Get(id PrimaryType, preloads ...string) (*ModelType, error) {
@@ -146,8 +374,8 @@ func (r Repository[ModelType, PrimaryType]) Get(id PrimaryType, preloads ...stri
 		err error
 		m   ModelType
 	)
-	if err = r.buildPreloads(r.db, preloads...).Take(&m, fmt.Sprintf("`%s`=?", r.model.PrimaryKey()), id).Error; err != nil {
-		return nil, err
+	if err = r.wherePrimary(r.buildPreloads(r.queryDB(), preloads...), id).Take(&m).Error; err != nil {
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, nil
 }
@@ -173,9 +401,9 @@ func (r Repository[ModelType, PrimaryType]) GetInOrderByLimitOffset(values []Pri
 		err error
 		m   []*ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
-	err = builder.Find(&m, values).Error
+	err = r.wherePrimaryIn(builder, values).Find(&m).Error
 	return m, err
 }
 
@@ -184,9 +412,9 @@ func (r Repository[ModelType, PrimaryType]) GetFirst(preloads ...string) (*Model
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	err = builder.First(&m).Error
-	return &m, err
+	return &m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetFirstByField(field string, value any, preloads ...string) (*ModelType, error) {
@@ -194,8 +422,8 @@ func (r Repository[ModelType, PrimaryType]) GetFirstByField(field string, value
 		err error
 		m   ModelType
 	)
-	if err = r.buildWhereCondition(r.buildPreloads(r.db, preloads...), field, value).First(&m).Error; err != nil {
-		return nil, err
+	if err = r.buildWhereCondition(r.buildPreloads(r.queryDB(), preloads...), field, value).First(&m).Error; err != nil {
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, nil
 }
@@ -205,12 +433,12 @@ func (r Repository[ModelType, PrimaryType]) GetFirstByConditions(conditions map[
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
 	if err = builder.First(&m).Error; err != nil {
-		return nil, err
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, err
 }
@@ -220,8 +448,8 @@ func (r Repository[ModelType, PrimaryType]) GetFirstOrderByLimitOffset(orderBy s
 		err error
 		m   ModelType
 	)
-	err = r.buildOrderByLimitOffset(r.buildPreloads(r.db, preloads...), orderBy, limit, offset).First(&m).Error
-	return &m, err
+	err = r.buildOrderByLimitOffset(r.buildPreloads(r.queryDB(), preloads...), orderBy, limit, offset).First(&m).Error
+	return &m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetFirstByFieldOrderByLimitOffset(field string, value any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error) {
@@ -229,11 +457,11 @@ func (r Repository[ModelType, PrimaryType]) GetFirstByFieldOrderByLimitOffset(fi
 		err error
 		m   *ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildWhereCondition(builder, field, value)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	err = builder.First(&m).Error
-	return m, err
+	return m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetFirstByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error) {
@@ -241,13 +469,13 @@ func (r Repository[ModelType, PrimaryType]) GetFirstByConditionsOrderByLimitOffs
 		err error
 		m   *ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	err = builder.First(&m).Error
-	return m, err
+	return m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetLast(preloads ...string) (*ModelType, error) {
@@ -255,9 +483,9 @@ func (r Repository[ModelType, PrimaryType]) GetLast(preloads ...string) (*ModelT
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	err = builder.Last(&m).Error
-	return &m, err
+	return &m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetLastByField(field string, value any, preloads ...string) (*ModelType, error) {
@@ -265,10 +493,10 @@ func (r Repository[ModelType, PrimaryType]) GetLastByField(field string, value a
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildWhereCondition(builder, field, value)
 	if err = builder.Last(&m).Error; err != nil {
-		return nil, err
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, nil
 }
@@ -278,12 +506,12 @@ func (r Repository[ModelType, PrimaryType]) GetLastByConditions(conditions map[s
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
 	if err = builder.Last(&m).Error; err != nil {
-		return nil, err
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, err
 }
@@ -293,8 +521,8 @@ func (r Repository[ModelType, PrimaryType]) GetLastOrderByLimitOffset(orderBy st
 		err error
 		m   ModelType
 	)
-	err = r.buildOrderByLimitOffset(r.buildPreloads(r.db, preloads...), orderBy, limit, offset).Last(&m).Error
-	return &m, err
+	err = r.buildOrderByLimitOffset(r.buildPreloads(r.queryDB(), preloads...), orderBy, limit, offset).Last(&m).Error
+	return &m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetLastByFieldOrderByLimitOffset(field string, value any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error) {
@@ -302,11 +530,11 @@ func (r Repository[ModelType, PrimaryType]) GetLastByFieldOrderByLimitOffset(fie
 		err error
 		m   *ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildWhereCondition(builder, field, value)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	err = builder.Last(&m).Error
-	return m, err
+	return m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetLastByConditionsOrderByLimitOffset(conditions map[string]any, orderBy string, limit, offset int, preloads ...string) (*ModelType, error) {
@@ -314,13 +542,13 @@ func (r Repository[ModelType, PrimaryType]) GetLastByConditionsOrderByLimitOffse
 		err error
 		m   *ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	err = builder.Last(&m).Error
-	return m, err
+	return m, r.wrapNotFound(err)
 }
 
 func (r Repository[ModelType, PrimaryType]) GetAll(preloads ...string) ([]*ModelType, error) {
@@ -344,7 +572,7 @@ func (r Repository[ModelType, PrimaryType]) GetAllOrderByLimitOffset(orderBy str
 		err error
 		m   []*ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	err = builder.Find(&m).Error
 	return m, err
@@ -355,10 +583,10 @@ func (r Repository[ModelType, PrimaryType]) GetByField(field string, value any,
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildWhereCondition(builder, field, value)
 	if err = builder.Take(&m).Error; err != nil {
-		return nil, err
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, nil
 }
@@ -368,12 +596,12 @@ func (r Repository[ModelType, PrimaryType]) GetByConditions(conditions map[strin
 		err error
 		m   ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
 	if err = builder.Take(&m).Error; err != nil {
-		return nil, err
+		return nil, r.wrapNotFound(err)
 	}
 	return &m, nil
 }
@@ -399,7 +627,7 @@ func (r Repository[ModelType, PrimaryType]) GetAllByFieldOrderByLimitOffset(fiel
 		err error
 		m   []*ModelType
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	builder = r.buildWhereCondition(builder, field, value)
 	err = builder.Find(&m).Error
@@ -427,7 +655,7 @@ func (r Repository[ModelType, PrimaryType]) GetAllByConditionsOrderByLimitOffset
 		err error
 		m   = make([]*ModelType, 0)
 	)
-	builder := r.buildPreloads(r.db, preloads...)
+	builder := r.buildPreloads(r.queryDB(), preloads...)
 	builder = r.buildOrderByLimitOffset(builder, orderBy, limit, offset)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
@@ -439,7 +667,7 @@ func (r Repository[ModelType, PrimaryType]) GetAllByConditionsOrderByLimitOffset
 }
 
 func (r Repository[ModelType, PrimaryType]) CountByField(field string, value any) (count int64, err error) {
-	builder := r.db.Model(&r.model)
+	builder := r.queryDB().Model(&r.model)
 	builder = r.buildWhereCondition(builder, field, value)
 	if err = builder.Count(&count).Error; err != nil {
 		return 0, err
@@ -448,7 +676,7 @@ func (r Repository[ModelType, PrimaryType]) CountByField(field string, value any
 }
 
 func (r Repository[ModelType, PrimaryType]) CountByConditions(conditions map[string]any) (count int64, err error) {
-	builder := r.db.Model(&r.model)
+	builder := r.queryDB().Model(&r.model)
 	for k, v := range conditions {
 		builder = r.buildWhereCondition(builder, k, v)
 	}
@@ -474,7 +702,66 @@ func (r Repository[ModelType, PrimaryType]) TableName() string {
 	return schema.NamingStrategy{}.TableName(t.Name())
 }
 
+// Strict 返回严格模式的 Repository, 按主键更新/删除未影响任何记录时返回 ErrNotFound
+// MySQL 未开启 clientFoundRows 时, 更新值与原值相同也会被视为未影响
+func (r Repository[ModelType, PrimaryType]) Strict() *Repository[ModelType, PrimaryType] {
+	r.strict = true
+	return &r
+}
+
+// updatesSelected 仅更新 params 中的列, 包括零值
+func (r Repository[ModelType, PrimaryType]) updatesSelected(builder *gorm.DB, params map[string]any) *gorm.DB {
+	selectedFields := make([]any, 0, len(params))
+	for k := range params {
+		selectedFields = append(selectedFields, k)
+	}
+	if len(selectedFields) > 0 {
+		builder = builder.Select(selectedFields[0], selectedFields[1:]...)
+	}
+	return builder.Updates(params)
+}
+
+func (r Repository[ModelType, PrimaryType]) checkAffected(result *gorm.DB) (int64, error) {
+	if result.Error != nil {
+		return result.RowsAffected, result.Error
+	}
+	if r.strict && result.RowsAffected == 0 {
+		return 0, ErrNotFound
+	}
+	return result.RowsAffected, nil
+}
+
+func (r Repository[ModelType, PrimaryType]) wrapNotFound(err error) error {
+	if err != nil && err != ErrNotFound && errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (r Repository[ModelType, PrimaryType]) parseSchema() (*schema.Schema, error) {
+	stmt := &gorm.Statement{DB: r.db}
+	if err := stmt.Parse(&r.model); err != nil {
+		return nil, err
+	}
+	return stmt.Schema, nil
+}
+
 func (r Repository[ModelType, PrimaryType]) buildWhereCondition(builder *gorm.DB, k string, v any) *gorm.DB {
+	if condition, ok := v.(clause.Expression); ok {
+		condition, err := r.resolveExpression(condition)
+		if err != nil {
+			builder = builder.Session(&gorm.Session{})
+			_ = builder.AddError(err)
+			return builder
+		}
+		return builder.Where(condition)
+	}
+	k, err := r.resolveColumn(k)
+	if err != nil {
+		builder = builder.Session(&gorm.Session{})
+		_ = builder.AddError(err)
+		return builder
+	}
 	if valuer, ok := v.(driver.Valuer); ok {
 		v, _ = valuer.Value()
 	}
@@ -487,13 +774,14 @@ func (r Repository[ModelType, PrimaryType]) buildWhereCondition(builder *gorm.DB
 	case reflect.Slice:
 		fallthrough
 	case reflect.Array:
-		builder = builder.Where(clause.IN{Column: k, Values: SliceToAnySlice(v)})
-	default:
-		if condition, ok := v.(clause.Expression); ok {
-			builder = builder.Where(condition)
-		} else {
+		//[]byte 及 [16]byte 等 UUID 按单个值处理
+		if t.Elem().Kind() == reflect.Uint8 {
 			builder = builder.Where(clause.Eq{Column: k, Value: v})
+			break
 		}
+		builder = builder.Where(clause.IN{Column: k, Values: SliceToAnySlice(v)})
+	default:
+		builder = builder.Where(clause.Eq{Column: k, Value: v})
 	}
 	return builder
 }
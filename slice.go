@@ -20,24 +20,11 @@ func SliceUnique[T comparable](arr []T) []T {
 func SliceExtractStructField[T any, K comparable](arr []T, fieldName string) ([]K, error) {
 	var result []K
 	for _, item := range arr {
-		rValue := reflect.ValueOf(item)
-		for rValue.Kind() == reflect.Pointer {
-			if rValue.IsNil() {
-				break
-			}
-			rValue = rValue.Elem()
+		key, err := sliceStructField[K](item, fieldName)
+		if err != nil {
+			return nil, err
 		}
-		if rValue.Kind() != reflect.Struct {
-			return nil, fmt.Errorf("%s is not struct type", rValue.Kind())
-		}
-		field := rValue.FieldByName(fieldName)
-		if !field.IsValid() {
-			return nil, fmt.Errorf("field %s not found in struct", fieldName)
-		}
-		if field.Type().String() != reflect.TypeOf((*K)(nil)).Elem().String() {
-			return nil, fmt.Errorf("field %s is not of type %T", fieldName, *new(K))
-		}
-		result = append(result, field.Interface().(K))
+		result = append(result, key)
 	}
 	return result, nil
 }
@@ -45,24 +32,11 @@ func SliceExtractStructField[T any, K comparable](arr []T, fieldName string) ([]
 func SliceStructIndex[T any, K comparable](data []T, fieldName string) (map[K]T, error) {
 	result := make(map[K]T)
 	for _, item := range data {
-		rValue := reflect.ValueOf(item)
-		for rValue.Kind() == reflect.Pointer {
-			if rValue.IsNil() {
-				break
-			}
-			rValue = rValue.Elem()
-		}
-		if rValue.Kind() != reflect.Struct {
-			return nil, fmt.Errorf("%s is not struct type", rValue.Kind())
-		}
-		field := rValue.FieldByName(fieldName)
-		if !field.IsValid() {
-			return nil, fmt.Errorf("field %s not found in struct", fieldName)
+		key, err := sliceStructField[K](item, fieldName)
+		if err != nil {
+			return nil, err
 		}
-		if field.Type().String() != reflect.TypeOf((*K)(nil)).Elem().String() {
-			return nil, fmt.Errorf("field %s is not of type %T", fieldName, *new(K))
-		}
-		result[field.Interface().(K)] = item
+		result[key] = item
 	}
 	return result, nil
 }
@@ -75,3 +49,37 @@ func SliceToAnySlice(v any) []any {
 	}
 	return values
 }
+
+func SliceStructGroup[T any, K comparable](data []T, fieldName string) (map[K][]T, error) {
+	result := make(map[K][]T)
+	for _, item := range data {
+		key, err := sliceStructField[K](item, fieldName)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = append(result[key], item)
+	}
+	return result, nil
+}
+
+// sliceStructField 取结构体(或其指针)中类型为 K 的字段值
+func sliceStructField[K comparable](item any, fieldName string) (key K, err error) {
+	rValue := reflect.ValueOf(item)
+	for rValue.Kind() == reflect.Pointer {
+		if rValue.IsNil() {
+			break
+		}
+		rValue = rValue.Elem()
+	}
+	if rValue.Kind() != reflect.Struct {
+		return key, fmt.Errorf("%s is not struct type", rValue.Kind())
+	}
+	field := rValue.FieldByName(fieldName)
+	if !field.IsValid() {
+		return key, fmt.Errorf("field %s not found in struct", fieldName)
+	}
+	if field.Type().String() != reflect.TypeOf((*K)(nil)).Elem().String() {
+		return key, fmt.Errorf("field %s is not of type %T", fieldName, *new(K))
+	}
+	return field.Interface().(K), nil
+}
@@ -30,8 +30,8 @@ func TestPubSub(t *testing.T) {
 	e.Publish(AttachmentTypePhoto, []byte("photo"))
 	e.Publish(AttachmentTypeVideo, []byte("video"))
 
-	e.Subscribe(ctxPhoto, AttachmentTypePhoto, photo)
-	e.Subscribe(ctxVideo, AttachmentTypeVideo, video)
+	e.SubscribeChan(ctxPhoto, AttachmentTypePhoto, photo)
+	e.SubscribeChan(ctxVideo, AttachmentTypeVideo, video)
 
 	e.Publish(AttachmentTypePhoto, []byte("1"))
 	e.Publish(AttachmentTypePhoto, []byte("2"))
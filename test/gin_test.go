@@ -0,0 +1,430 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"embed"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jqqjj/go-utils"
+)
+
+//go:embed testdata/static
+var staticFS embed.FS
+
+//go:embed all:testdata/templates
+var templateFS embed.FS
+
+func newStaticGin(config utils.GinConfig) *utils.Gin {
+	config.StaticEmbed, config.StaticPathBase, config.StaticUrlBase = staticFS, "testdata/static", "static"
+	return utils.NewGin(config)
+}
+
+func serveGin(g http.Handler, target string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, req)
+	return w
+}
+
+func TestGinStaticETag(t *testing.T) {
+	g := newStaticGin(utils.GinConfig{CacheMinute: 5})
+
+	w := serveGin(g, "/static/js/small.js", nil)
+	if w.Code != http.StatusOK || w.Body.String() != "console.log('small');\n" {
+		t.Fatal(w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" || w.Header().Get("Cache-Control") != "max-age=300, must-revalidate" {
+		t.Error("headers", w.Header())
+	}
+	if w = serveGin(g, "/static/js/small.js", map[string]string{"If-None-Match": etag}); w.Code != http.StatusNotModified {
+		t.Error("if-none-match", w.Code)
+	}
+	if w = serveGin(g, "/static/js/missing.js", nil); w.Code != http.StatusNotFound {
+		t.Error("missing", w.Code)
+	}
+}
+
+func TestGinStaticEncoding(t *testing.T) {
+	g := newStaticGin(utils.GinConfig{})
+	original, err := os.ReadFile("testdata/static/js/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		target, accept, encoding string
+	}{
+		{"/static/js/app.js", "", ""},
+		{"/static/js/app.js", "gzip, deflate, br", "gzip"},
+		{"/static/js/app.js", "GZIP;q=0.5", "gzip"},
+		{"/static/js/app.js", "*", "gzip"},
+		{"/static/js/app.js", "gzip;q=0", ""},
+		{"/static/js/app.js", "gzip; q=0.0, br", ""},
+		{"/static/js/app.js", "br", ""},
+		//小文件不压缩
+		{"/static/js/small.js", "gzip", ""},
+		//优先使用预压缩文件
+		{"/static/data/list.json", "gzip, br", "br"},
+		{"/static/data/list.json", "br;q=0, gzip", ""},
+	} {
+		w := serveGin(g, v.target, map[string]string{"Accept-Encoding": v.accept})
+		if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != v.encoding {
+			t.Error(v.target, v.accept, w.Code, w.Header().Get("Content-Encoding"))
+			continue
+		}
+		if v.target != "/static/js/small.js" && !strings.Contains(w.Header().Get("Vary"), "Accept-Encoding") {
+			t.Error(v.target, v.accept, "vary", w.Header())
+		}
+
+		switch {
+		case v.target == "/static/js/app.js" && v.encoding == "gzip":
+			r, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, _ := io.ReadAll(r); !bytes.Equal(data, original) {
+				t.Error("gunzipped content mismatch")
+			}
+		case v.encoding == "br":
+			if w.Body.String() != "precompressed-br" || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+				t.Error("precompressed", w.Body.String(), w.Header())
+			}
+		}
+	}
+}
+
+func TestGinStaticFingerprint(t *testing.T) {
+	g := newStaticGin(utils.GinConfig{CacheMinute: 5})
+
+	url := g.AssetURL("js/app.js")
+	if !regexp.MustCompile(`^/static/js/app\.[0-9a-f]{8}\.js$`).MatchString(url) {
+		t.Fatal("asset url", url)
+	}
+	if g.AssetManifest()["js/app.js"] != strings.TrimPrefix(url, "/static/") {
+		t.Error("manifest", g.AssetManifest())
+	}
+	if v := g.AssetURL("missing.css"); v != "/static/missing.css" {
+		t.Error("missing asset url", v)
+	}
+
+	w := serveGin(g, url, nil)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "public, max-age=31536000, immutable" {
+		t.Error("fingerprinted", w.Code, w.Header())
+	}
+	//指纹与内容不符时不按带指纹的文件处理
+	if w = serveGin(g, "/static/js/app.00000000.js", nil); w.Code != http.StatusNotFound {
+		t.Error("wrong fingerprint", w.Code)
+	}
+	if w = serveGin(g, "/static/js/app.js", nil); w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "max-age=300, must-revalidate" {
+		t.Error("original name", w.Code, w.Header())
+	}
+}
+
+func TestGinTemplateLayouts(t *testing.T) {
+	g := newStaticGin(utils.GinConfig{
+		TemplateEmbed:    templateFS,
+		TemplatePathBase: "testdata/templates",
+		TemplateLayout:   "layout.html",
+		TemplateLayouts:  map[string]string{"admin": "admin/layout.html", "plain": "-"},
+	})
+	for _, name := range []string{"home.html", "about.html", "admin/users.html", "plain/asset.html"} {
+		name := name
+		g.GET("/"+name, func(c *gin.Context) { c.HTML(http.StatusOK, name, "D") })
+	}
+
+	for _, v := range []struct{ target, want string }{
+		{"/home.html", "<html><title>Home</title><nav>D</nav><main>home D</main></html>"},
+		//未定义的块不会沿用其他页面的定义
+		{"/about.html", "<html><title>Default</title><nav>D</nav><main>about</main></html>"},
+		{"/admin/users.html", "<admin>admin users</admin>"},
+		{"/plain/asset.html", `<script src="` + g.AssetURL("js/app.js") + `"></script>`},
+	} {
+		w := serveGin(g, v.target, nil)
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != v.want {
+			t.Error(v.target, w.Code, w.Body.String())
+		}
+	}
+}
+
+// tempDir 模板及静态目录配置会去掉开头的 "/", 因此在当前目录下创建临时目录
+func tempDir(t *testing.T) string {
+	dir, err := os.MkdirTemp(".", "gin-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	return dir
+}
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		name = filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		//部分文件系统的修改时间精度较低, 同时修改大小与时间保证签名变化
+		future := time.Now().Add(time.Duration(len(content)) * time.Second)
+		if err := os.Chtimes(name, future, future); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestGinDebugTemplateFiles(t *testing.T) {
+	dir := tempDir(t)
+
+	//隐藏文件、编辑器临时文件及非模板扩展名的文件即使内容无法解析也不加载
+	writeFiles(t, dir, map[string]string{
+		"page.html":           "page {{.}}",
+		"extra.tmpl":          "extra {{.}}",
+		".DS_Store":           "{{",
+		".page.html.swp":      "{{",
+		"page.html~":          "{{",
+		"notes.txt":           "{{",
+		".git/HEAD.html":      "{{",
+		"sub/nested.html":     "nested {{.}}",
+		"sub/.nested.html.sw": "{{",
+	})
+
+	g := newStaticGin(utils.GinConfig{Debug: true, TemplatePathBase: dir, ReloadInterval: time.Hour})
+	t.Cleanup(g.Close)
+	for _, name := range []string{"page.html", "extra.tmpl", "sub/nested.html"} {
+		name := name
+		g.GET("/"+name, func(c *gin.Context) { c.HTML(http.StatusOK, name, "D") })
+	}
+	for _, v := range []struct{ target, want string }{
+		{"/page.html", "page D"},
+		{"/extra.tmpl", "extra D"},
+		{"/sub/nested.html", "nested D"},
+	} {
+		w := serveGin(g, v.target, nil)
+		if w.Code != http.StatusOK || w.Body.String() != v.want {
+			t.Error(v.target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestGinDebugEmptyTemplateBase(t *testing.T) {
+	//未设置模板目录时不遍历工作目录, 其中无法解析的 html 文件不影响嵌入的模板
+	broken, err := os.CreateTemp(".", "broken-*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Remove(broken.Name()) })
+	if _, err = broken.WriteString("{{"); err != nil {
+		t.Fatal(err)
+	}
+	_ = broken.Close()
+
+	g := newStaticGin(utils.GinConfig{Debug: true, TemplateEmbed: templateFS, ReloadInterval: time.Hour})
+	t.Cleanup(g.Close)
+	g.GET("/", func(c *gin.Context) { c.HTML(http.StatusOK, "testdata/templates/about.html", nil) })
+	if w := serveGin(g, "/", nil); w.Code != http.StatusOK {
+		t.Error("empty template base", w.Code, w.Body.String())
+	}
+}
+
+// reloadEvents 订阅调试模式的 SSE, 将 reload 事件的数据发送到通道, 连接断开时关闭通道
+func reloadEvents(t *testing.T, url string) <-chan string {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" || resp.Header.Get("Cache-Control") != "no-cache" {
+		t.Fatal("sse headers", resp.StatusCode, resp.Header)
+	}
+
+	events := make(chan string, 8)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data:"); ok {
+				events <- strings.TrimSpace(data)
+			}
+		}
+	}()
+	return events
+}
+
+func expectReload(t *testing.T, events <-chan string, want string) {
+	t.Helper()
+	select {
+	case v := <-events:
+		if v != want {
+			t.Error("reload event", v, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("reload event timeout", want)
+	}
+}
+
+func expectNoReload(t *testing.T, events <-chan string) {
+	t.Helper()
+	select {
+	case v := <-events:
+		t.Error("unexpected reload event", v)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func getBody(t *testing.T, url string) (int, string) {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestGinReload(t *testing.T) {
+	dir := tempDir(t)
+	templates, statics := filepath.Join(dir, "templates"), filepath.Join(dir, "static")
+	writeFiles(t, templates, map[string]string{"page.html": "v1"})
+	writeFiles(t, statics, map[string]string{"app.js": "v1"})
+
+	g := utils.NewGin(utils.GinConfig{
+		Debug:            true,
+		TemplatePathBase: templates,
+		StaticPathBase:   statics,
+		StaticUrlBase:    "static",
+		ReloadInterval:   10 * time.Millisecond,
+	})
+	t.Cleanup(g.Close)
+	g.GET("/page", func(c *gin.Context) { c.HTML(http.StatusOK, "page.html", nil) })
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+
+	if code, body := getBody(t, server.URL+"/page"); code != http.StatusOK || body != "v1" {
+		t.Fatal("page", code, body)
+	}
+	events := reloadEvents(t, server.URL+"/__reload")
+
+	//隐藏文件、备份文件及模板目录中的非模板文件不触发刷新
+	writeFiles(t, templates, map[string]string{".page.html.swp": "x", "page.html~": "x", "notes.txt": "x", ".git/index": "x"})
+	writeFiles(t, statics, map[string]string{".DS_Store": "x", "app.js~": "x"})
+	expectNoReload(t, events)
+
+	//模板修改后清空缓存
+	writeFiles(t, templates, map[string]string{"page.html": "v2 changed"})
+	expectReload(t, events, "template")
+	if code, body := getBody(t, server.URL+"/page"); code != http.StatusOK || body != "v2 changed" {
+		t.Error("reloaded page", code, body)
+	}
+
+	writeFiles(t, statics, map[string]string{"app.js": "v2 changed"})
+	expectReload(t, events, "static")
+	if code, body := getBody(t, server.URL+"/static/app.js"); code != http.StatusOK || body != "v2 changed" {
+		t.Error("reloaded static", code, body)
+	}
+
+	//删除文件同样触发刷新
+	if err := os.Remove(filepath.Join(statics, "app.js")); err != nil {
+		t.Fatal(err)
+	}
+	expectReload(t, events, "static")
+
+	//Close 后结束 SSE
+	g.Close()
+	select {
+	case _, ok := <-events:
+		for ok {
+			_, ok = <-events
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("sse not closed")
+	}
+}
+
+func TestGinReloadEmptyBase(t *testing.T) {
+	g := utils.NewGin(utils.GinConfig{Debug: true, StaticUrlBase: "static", ReloadInterval: 10 * time.Millisecond})
+	t.Cleanup(g.Close)
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+	events := reloadEvents(t, server.URL+"/__reload")
+
+	//未设置目录时不监听工作目录
+	file, err := os.CreateTemp(".", "reload-*.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Remove(file.Name()) })
+	_ = file.Close()
+	writeFiles(t, ".", map[string]string{file.Name(): "changed"})
+	expectNoReload(t, events)
+}
+
+func TestGinReloadDisabled(t *testing.T) {
+	g := newStaticGin(utils.GinConfig{})
+	if w := serveGin(g, "/__reload", nil); w.Code != http.StatusNotFound {
+		t.Error("reload path in release mode", w.Code)
+	}
+}
+
+func TestGinTemplateErrorPage(t *testing.T) {
+	//发布模式不输出错误详情
+	g := newStaticGin(utils.GinConfig{TemplateEmbed: templateFS, TemplatePathBase: "testdata/templates"})
+	g.GET("/broken", func(c *gin.Context) { c.HTML(http.StatusOK, "errors/broken.html", nil) })
+	w := serveGin(g, "/broken", nil)
+	if w.Code != http.StatusInternalServerError || w.Body.String() != http.StatusText(http.StatusInternalServerError) {
+		t.Error("release error page", w.Code, w.Body.String())
+	}
+
+	for _, v := range []struct {
+		name    string
+		files   map[string]string
+		page    string
+		message string
+	}{
+		{"execute", map[string]string{"page.html": `<article>{{index . 1}}</article>`}, "page.html", "index of untyped nil"},
+		{"escape", map[string]string{"page.html": `<article>{{template "missing" .}}</article>`}, "page.html", `no such template &#34;missing&#34;`},
+		{"parse", map[string]string{"page.html": "{{", "other.html": "other"}, "other.html", "unclosed action"},
+		{"not found", map[string]string{"page.html": "page"}, "missing.html", "template missing.html not found"},
+	} {
+		dir := tempDir(t)
+		writeFiles(t, dir, v.files)
+		g := newStaticGin(utils.GinConfig{Debug: true, TemplatePathBase: dir, ReloadInterval: time.Hour})
+		page := v.page
+		g.GET("/", func(c *gin.Context) { c.HTML(http.StatusOK, page, nil) })
+
+		w := serveGin(g, "/", nil)
+		body := w.Body.String()
+		if w.Code != http.StatusInternalServerError || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+			t.Error(v.name, w.Code, w.Header())
+		}
+		//错误页面包含页面名、错误详情及刷新脚本, 输出前的部分结果被丢弃
+		for _, want := range []string{"Template error: " + v.page, v.message, `new EventSource("/__reload")`} {
+			if !strings.Contains(body, want) {
+				t.Error(v.name, want, body)
+			}
+		}
+		if strings.Contains(body, "<article>") {
+			t.Error(v.name, "partial output", body)
+		}
+		g.Close()
+	}
+}
@@ -0,0 +1,320 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm"
+)
+
+type benchAddress struct {
+	City   string
+	Street *string
+}
+
+type benchEntity struct {
+	gorm.Model
+	Name     string `gorm:"column:user_name"`
+	Age      uint8
+	Score    float64
+	Birthday time.Time
+	Remark   *string
+	Avatar   []byte
+	Home     benchAddress `gorm:"embedded;embeddedPrefix:home_"`
+	Status   utils.JsonNullInt64
+}
+
+func BenchmarkGormEntityToMap(b *testing.B) {
+	remark := "remark"
+	entity := &benchEntity{Name: "name", Age: 18, Score: 99.5, Birthday: time.Now(), Remark: &remark, Home: benchAddress{City: "city"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := utils.GormEntityToMap(entity); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGormMapToEntity(b *testing.B) {
+	remark := "remark"
+	m, err := utils.GormEntityToMap(&benchEntity{Name: "name", Age: 18, Score: 99.5, Birthday: time.Now(), Remark: &remark, Home: benchAddress{City: "city"}})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var entity benchEntity
+		if err = utils.GormMapToEntity(m, &entity); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+type mapAddress struct {
+	City   string
+	Street *string
+}
+
+type mapEntity struct {
+	ID       uint64
+	Level    uint8
+	Rank     uint32
+	Name     string `gorm:"column:user_name"`
+	Remark   *string
+	Count    *int
+	Birthday time.Time
+	Avatar   []byte
+	Home     mapAddress `gorm:"embedded;embeddedPrefix:home_"`
+	Status   utils.JsonNullInt64
+	Tags     []string `gorm:"serializer:json"`
+	Ignored  string   `gorm:"-"`
+}
+
+func TestGormEntityToMap(t *testing.T) {
+	remark, count, street := "remark", 3, "street"
+	birthday := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	entity := &mapEntity{
+		ID: 1<<63 + 1, Level: 255, Rank: 1 << 31, Name: "name", Count: &count, Birthday: birthday,
+		Avatar: []byte("avatar"), Home: mapAddress{City: "city", Street: &street},
+		Status: utils.JsonNullInt64{Int64: 2, Valid: true}, Tags: []string{"a", "b"}, Ignored: "ignored",
+	}
+	m, err := utils.GormEntityToMap(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		column string
+		value  any
+	}{
+		{"id", uint64(1<<63 + 1)},
+		{"level", uint8(255)},
+		{"rank", uint32(1 << 31)},
+		{"user_name", "name"},
+		//nil 指针为 NULL
+		{"remark", nil},
+		{"count", 3},
+		{"birthday", birthday},
+		{"avatar", []byte("avatar")},
+		{"home_city", "city"},
+		{"home_street", "street"},
+		{"status", int64(2)},
+		{"tags", `["a","b"]`},
+	} {
+		got, ok := m[v.column]
+		if !ok || !reflect.DeepEqual(got, v.value) {
+			t.Error("column", v.column, got, v.value)
+		}
+	}
+	for _, column := range []string{"ignored", "home", "name"} {
+		if _, ok := m[column]; ok {
+			t.Error("unexpected column", column)
+		}
+	}
+	if len(m) != 12 {
+		t.Error("columns", len(m), m)
+	}
+	remark = ""
+	entity.Remark = &remark
+	if m, err = utils.GormEntityToMap(entity); err != nil || m["remark"] != "" {
+		t.Error("remark", m["remark"], err)
+	}
+}
+
+func TestGormMapToEntity(t *testing.T) {
+	birthday := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for _, v := range []struct {
+		name  string
+		m     map[string]any
+		check func(entity *mapEntity) bool
+	}{
+		{"uint from int64", map[string]any{"id": int64(7), "level": int64(255), "rank": "9"}, func(e *mapEntity) bool {
+			return e.ID == 7 && e.Level == 255 && e.Rank == 9
+		}},
+		{"string from bytes", map[string]any{"user_name": []byte("name")}, func(e *mapEntity) bool {
+			return e.Name == "name"
+		}},
+		{"pointer", map[string]any{"remark": "remark", "count": int64(3)}, func(e *mapEntity) bool {
+			return e.Remark != nil && *e.Remark == "remark" && e.Count != nil && *e.Count == 3
+		}},
+		{"null pointer", map[string]any{"remark": nil, "count": nil}, func(e *mapEntity) bool {
+			return e.Remark == nil && e.Count == nil
+		}},
+		{"time", map[string]any{"birthday": birthday}, func(e *mapEntity) bool {
+			return e.Birthday.Equal(birthday)
+		}},
+		{"time from string", map[string]any{"birthday": "2024-05-06 07:08:09"}, func(e *mapEntity) bool {
+			return e.Birthday.Equal(birthday)
+		}},
+		{"bytes", map[string]any{"avatar": "avatar"}, func(e *mapEntity) bool {
+			return string(e.Avatar) == "avatar"
+		}},
+		{"embedded prefix", map[string]any{"home_city": "city", "home_street": "street"}, func(e *mapEntity) bool {
+			return e.Home.City == "city" && e.Home.Street != nil && *e.Home.Street == "street"
+		}},
+		{"scanner", map[string]any{"status": int64(2)}, func(e *mapEntity) bool {
+			return e.Status.Valid && e.Status.Int64 == 2
+		}},
+		{"json serializer", map[string]any{"tags": `["a","b"]`}, func(e *mapEntity) bool {
+			return reflect.DeepEqual(e.Tags, []string{"a", "b"})
+		}},
+		{"unknown column", map[string]any{"ignored": "ignored", "name": "name"}, func(e *mapEntity) bool {
+			return e.Ignored == "" && e.Name == ""
+		}},
+	} {
+		//指针字段有旧值, 验证 NULL 会将其置为 nil
+		remark, count := "old", 1
+		entity := &mapEntity{Remark: &remark, Count: &count}
+		if v.name != "null pointer" && v.name != "pointer" {
+			entity.Remark, entity.Count = nil, nil
+		}
+		if err := utils.GormMapToEntity(v.m, entity); err != nil {
+			t.Error(v.name, err)
+			continue
+		}
+		if !v.check(entity) {
+			t.Error(v.name, *entity)
+		}
+	}
+
+	//溢出与无法转换的值返回错误
+	for _, m := range []map[string]any{
+		{"level": int64(256)},
+		{"level": int64(-1)},
+		{"birthday": "not a time"},
+	} {
+		if err := utils.GormMapToEntity(m, &mapEntity{}); err == nil {
+			t.Error("expected error", m)
+		}
+	}
+}
+
+func TestGormMapRoundTrip(t *testing.T) {
+	remark, street := "remark", "street"
+	entity := &mapEntity{
+		ID: 1<<63 + 1, Level: 8, Rank: 9, Name: "name", Remark: &remark, Birthday: time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC),
+		Avatar: []byte("avatar"), Home: mapAddress{City: "city", Street: &street},
+		Status: utils.JsonNullInt64{Int64: 2, Valid: true}, Tags: []string{"a"},
+	}
+	m, err := utils.GormEntityToMap(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got mapEntity
+	if err = utils.GormMapToEntity(m, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&got, entity) {
+		t.Error("round trip", got, *entity)
+	}
+}
+
+type pointerEmbedEntity struct {
+	*gorm.Model
+	Name string
+	Addr *mapAddress `gorm:"embedded;embeddedPrefix:addr_"`
+}
+
+func TestGormPointerEmbedded(t *testing.T) {
+	//嵌入的指针为 nil 时各列为 NULL
+	m, err := utils.GormEntityToMap(&pointerEmbedEntity{Name: "name"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{"id", "created_at", "updated_at", "deleted_at", "addr_city", "addr_street"} {
+		if v, ok := m[column]; !ok || v != nil {
+			t.Error("nil embedded", column, v, ok)
+		}
+	}
+	if m["name"] != "name" {
+		t.Error("name", m["name"])
+	}
+
+	street := "street"
+	entity := &pointerEmbedEntity{Model: &gorm.Model{ID: 3}, Name: "name", Addr: &mapAddress{City: "city", Street: &street}}
+	if m, err = utils.GormEntityToMap(entity); err != nil {
+		t.Fatal(err)
+	}
+	if m["id"] != uint(3) || m["addr_city"] != "city" || m["addr_street"] != "street" {
+		t.Error("embedded", m)
+	}
+
+	//写入时分配嵌入的指针
+	var got pointerEmbedEntity
+	if err = utils.GormMapToEntity(map[string]any{"id": int64(5), "addr_city": "city"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model == nil || got.ID != 5 || got.Addr == nil || got.Addr.City != "city" {
+		t.Error("map to entity", got)
+	}
+
+	//未出现的列不分配指针
+	got = pointerEmbedEntity{}
+	if err = utils.GormMapToEntity(map[string]any{"name": "name"}, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != nil || got.Addr != nil || got.Name != "name" {
+		t.Error("untouched embedded", got)
+	}
+}
+
+type diffEntity struct {
+	ID        int64
+	Name      string
+	Avatar    []byte
+	Event     EventType
+	Expire    utils.JsonNullTime
+	Birthday  time.Time
+	UpdatedAt time.Time
+}
+
+func TestGormEntityDiff(t *testing.T) {
+	birthday := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	base := diffEntity{
+		ID: 1, Name: "name", Avatar: []byte("avatar"), Event: ClickEvent,
+		Expire: utils.JsonNullTime{Time: birthday, Valid: true}, Birthday: birthday, UpdatedAt: birthday,
+	}
+
+	for _, v := range []struct {
+		name    string
+		change  func(e *diffEntity)
+		ignores []string
+		diff    map[string]any
+	}{
+		{"unchanged", func(e *diffEntity) {}, nil, map[string]any{}},
+		{"scalar", func(e *diffEntity) { e.Name = "new" }, nil, map[string]any{"name": "new"}},
+		{"bytes equal", func(e *diffEntity) { e.Avatar = []byte("avatar") }, nil, map[string]any{}},
+		{"bytes", func(e *diffEntity) { e.Avatar = []byte("new") }, nil, map[string]any{"avatar": []byte("new")}},
+		//同一时刻不同时区视为相同
+		{"time location", func(e *diffEntity) { e.Birthday = birthday.In(time.FixedZone("UTC+8", 8*3600)) }, nil, map[string]any{}},
+		{"time", func(e *diffEntity) { e.Birthday = birthday.Add(time.Second) }, nil, map[string]any{"birthday": birthday.Add(time.Second)}},
+		//Valuer 按 Value() 比较
+		{"enum", func(e *diffEntity) { e.Event = MouseEvent }, nil, map[string]any{"event": int64(1)}},
+		{"enum unset", func(e *diffEntity) { e.Event = EventType{} }, nil, map[string]any{"event": nil}},
+		{"null time equal", func(e *diffEntity) { e.Expire.Time = birthday.Add(time.Millisecond) }, nil, map[string]any{}},
+		{"null time", func(e *diffEntity) { e.Expire.Valid = false }, nil, map[string]any{"expire": nil}},
+		{"ignores", func(e *diffEntity) { e.Name, e.UpdatedAt = "new", time.Now() }, []string{"updated_at"}, map[string]any{"name": "new"}},
+		{"ignores unknown", func(e *diffEntity) { e.ID = 2 }, []string{"updated_at", "missing"}, map[string]any{"id": int64(2)}},
+	} {
+		entity := base
+		v.change(&entity)
+		diff, err := utils.GormEntityDiff(&base, &entity, v.ignores...)
+		if err != nil {
+			t.Error(v.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(diff, v.diff) {
+			t.Error(v.name, diff, v.diff)
+		}
+	}
+
+	if _, err := utils.GormEntityDiff(&base, &mapEntity{}); err == nil {
+		t.Error("expected type mismatch error")
+	}
+}
@@ -0,0 +1,196 @@
+package test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jqqjj/go-utils"
+)
+
+type counter struct {
+	ID    uint64 `gorm:"primaryKey"`
+	Name  string `json:"name"`
+	Total int64
+	Rate  float64
+	Note  *string
+}
+
+func (counter) PrimaryKey() string { return "id" }
+
+type counterResponse struct {
+	ID   uint64 `json:"id"`
+	Name string `json:"name"`
+}
+
+type counterPresenter struct{}
+
+func (counterPresenter) Present(entity *counter) *counterResponse {
+	return &counterResponse{ID: entity.ID, Name: entity.Name}
+}
+
+// batchPresenter 批量输出, 记录调用次数
+type batchPresenter struct {
+	counterPresenter
+	calls *int
+}
+
+func (p batchPresenter) PresentMany(entities []*counter) []*counterResponse {
+	*p.calls++
+	collection := make([]*counterResponse, 0, len(entities))
+	for _, v := range entities {
+		collection = append(collection, p.Present(v))
+	}
+	return collection
+}
+
+// brokenBatchPresenter 批量输出的数量与实体不一致
+type brokenBatchPresenter struct {
+	counterPresenter
+}
+
+func (brokenBatchPresenter) PresentMany(entities []*counter) []*counterResponse {
+	return make([]*counterResponse, 0)
+}
+
+func TestParsePaginationSort(t *testing.T) {
+	for _, v := range []struct {
+		s    string
+		want []utils.PaginationSort
+		err  bool
+	}{
+		{"", []utils.PaginationSort{}, false},
+		{"name", []utils.PaginationSort{{Column: "name"}}, false},
+		{"-created_at, +name ,id", []utils.PaginationSort{{Column: "created_at", Descending: true}, {Column: "name"}, {Column: "id"}}, false},
+		{"name,,", []utils.PaginationSort{{Column: "name"}}, false},
+		{"-", nil, true},
+		{"name,+", nil, true},
+	} {
+		sorts, err := utils.ParsePaginationSort(v.s)
+		if (err != nil) != v.err {
+			t.Error(v.s, err)
+			continue
+		}
+		if !v.err && !reflect.DeepEqual(sorts, v.want) {
+			t.Error(v.s, sorts)
+		}
+	}
+}
+
+func TestPaginationConditions(t *testing.T) {
+	repo := utils.NewRepositoryMemory[counter, uint64]()
+	for _, name := range []string{"a", "b", "b", "c"} {
+		_ = repo.Create(&counter{Name: name, Total: int64(len(name))})
+	}
+
+	//SetCondition/SetFilter 不修改调用方的条件
+	conditions := map[string]any{"total": 1}
+	p := utils.NewPaginationFor[counter, uint64, counterResponse](repo, conditions, counterPresenter{}).
+		SetCondition("name", "b").
+		SetSortString("-id")
+	resp, err := p.Paginate(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conditions) != 1 {
+		t.Error("caller conditions modified", conditions)
+	}
+	if resp.Total != 2 || len(resp.Items) != 2 || resp.Items[0].ID != 3 {
+		t.Error("paginate", resp.Total, resp.Items)
+	}
+
+	if _, err = utils.NewPaginationFor[counter, uint64, counterResponse](repo, nil, counterPresenter{}).SetSortString("-unknown").Paginate(1, 10); err == nil {
+		t.Error("unknown sort column should fail")
+	}
+
+	//批量输出只调用一次, 数量不一致时报错
+	calls := 0
+	if resp, err = utils.NewPaginationFor[counter, uint64, counterResponse](repo, nil, batchPresenter{calls: &calls}).Paginate(1, 10); err != nil || calls != 1 || len(resp.Items) != 4 {
+		t.Error("batch presenter", calls, resp, err)
+	}
+	if _, err = utils.NewPaginationFor[counter, uint64, counterResponse](repo, nil, brokenBatchPresenter{}).Paginate(1, 10); err == nil {
+		t.Error("batch presenter length mismatch should fail")
+	}
+}
+
+func TestNewPagination(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+	if err := repo.Create(&article{Title: "a"}, &article{Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//类型参数可由 *Repository 与 IPresenter 推断
+	var presenter utils.IPresenter[article, articleResponse] = articlePresenter{}
+	resp, err := utils.NewPagination(repo, map[string]any{"name": "b"}, presenter).Paginate(1, 10)
+	if err != nil || resp.Total != 1 || resp.Items[0].Title != "b" {
+		t.Error("paginate", resp, err)
+	}
+}
+
+func TestBindPaginationGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bind := func(query string, config utils.PaginationGinConfig) (*utils.PaginationGinParams, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/list?"+query, nil)
+		return utils.BindPaginationGin(c, config)
+	}
+
+	params, err := bind("", utils.PaginationGinConfig{})
+	if err != nil || params.Page != 1 || params.PerPage != 15 {
+		t.Error("defaults", params, err)
+	}
+	//默认每页最多 100 条, MaxPerPage 小于 0 时不限制
+	if params, err = bind("per_page=1000", utils.PaginationGinConfig{}); err != nil || params.PerPage != 100 {
+		t.Error("max per page", params, err)
+	}
+	if params, err = bind("per_page=1000", utils.PaginationGinConfig{MaxPerPage: -1}); err != nil || params.PerPage != 1000 {
+		t.Error("unlimited per page", params, err)
+	}
+	if _, err = bind("page=0", utils.PaginationGinConfig{}); err == nil {
+		t.Error("invalid page should fail")
+	}
+
+	params, err = bind("status=1,2&status=3&kind=a&other=x&sort=-id", utils.PaginationGinConfig{Filters: map[string]string{"status": "status", "kind": "type"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(params.Conditions, map[string]any{"status": []string{"1", "2", "3"}, "type": "a"}) || params.Sort != "-id" {
+		t.Error("filters", params.Conditions, params.Sort)
+	}
+}
+
+func TestWritePaginationGin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	resp := &utils.PaginationResponse[counterResponse]{Page: 2, PerPage: 10, Total: 25}
+	link := func(headers map[string]string) string {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "http://example.com/list?sort=-id", nil)
+		for k, v := range headers {
+			c.Request.Header.Set(k, v)
+		}
+		utils.WritePaginationGin(c, resp, utils.PaginationGinConfig{})
+		if w.Header().Get("X-Total-Count") != "25" {
+			t.Error("total count", w.Header())
+		}
+		return w.Header().Get("Link")
+	}
+
+	want := `<http://example.com/list?page=1&per_page=10&sort=-id>; rel="first", ` +
+		`<http://example.com/list?page=1&per_page=10&sort=-id>; rel="prev", ` +
+		`<http://example.com/list?page=3&per_page=10&sort=-id>; rel="next", ` +
+		`<http://example.com/list?page=3&per_page=10&sort=-id>; rel="last"`
+	if v := link(nil); v != want {
+		t.Error("link", v)
+	}
+	if v := link(map[string]string{"X-Forwarded-Proto": "HTTPS"}); !strings.HasPrefix(v, "<https://example.com/list?") {
+		t.Error("forwarded https", v)
+	}
+	//只接受 http/https, 避免客户端伪造任意 scheme
+	if v := link(map[string]string{"X-Forwarded-Proto": "javascript"}); !strings.HasPrefix(v, "<http://example.com/list?") {
+		t.Error("forwarded scheme", v)
+	}
+}
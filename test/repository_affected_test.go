@@ -0,0 +1,75 @@
+package test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm"
+)
+
+func TestRepositoryAffected(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+	if err := repo.Create(&article{ID: 1, Title: "a"}, &article{ID: 2, Title: "b"}, &article{ID: 3, Title: "c", Hits: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := repo.UpdateAffected(1, "hits", 2); err != nil || n != 1 {
+		t.Error("update affected", n, err)
+	}
+	if n, err := repo.UpdatesAffected(9, map[string]any{"hits": 2}); err != nil || n != 0 {
+		t.Error("updates missing", n, err)
+	}
+	if n, err := repo.UpdatesByConditionsAffected(map[string]any{"hits": 0}, map[string]any{"hits": 5}); err != nil || n != 1 {
+		t.Error("updates by conditions affected", n, err)
+	}
+	if n, err := repo.DeleteByConditionsAffected(map[string]any{"hits": 9}); err != nil || n != 0 {
+		t.Error("delete by conditions affected", n, err)
+	}
+	if n, err := repo.DeleteAffected(3); err != nil || n != 1 {
+		t.Error("delete affected", n, err)
+	}
+}
+
+func TestRepositoryStrict(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+	if err := repo.Create(&article{ID: 1, Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//非严格模式下未命中不报错
+	if err := repo.Update(9, "hits", 1); err != nil {
+		t.Error("update missing", err)
+	}
+	if err := repo.Delete(9); err != nil {
+		t.Error("delete missing", err)
+	}
+
+	strict := repo.Strict()
+	if err := strict.Update(9, "hits", 1); err != utils.ErrNotFound {
+		t.Error("strict update missing", err)
+	}
+	if err := strict.Updates(9, map[string]any{"hits": 1}); err != utils.ErrNotFound {
+		t.Error("strict updates missing", err)
+	}
+	if err := strict.Delete(9); err != utils.ErrNotFound {
+		t.Error("strict delete missing", err)
+	}
+	if err := strict.Update(1, "hits", 1); err != nil {
+		t.Error("strict update", err)
+	}
+
+	//读操作统一返回 ErrNotFound, 同时可按 gorm.ErrRecordNotFound 判断
+	for name, fn := range map[string]func() error{
+		"Get":            func() error { _, err := repo.Get(9); return err },
+		"GetByField":     func() error { _, err := repo.GetByField("title", "x"); return err },
+		"GetFirst":       func() error { _, err := repo.GetFirstByConditions(map[string]any{"hits": 9}); return err },
+		"GetLastByField": func() error { _, err := repo.GetLastByField("hits", 9); return err },
+	} {
+		if err := fn(); err != utils.ErrNotFound || !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Error(name, err)
+		}
+	}
+}
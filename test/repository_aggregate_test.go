@@ -0,0 +1,80 @@
+package test
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm/clause"
+)
+
+func TestRepositoryAggregate(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+	if err := repo.Create(&article{Title: "a", Hits: 1}, &article{Title: "b", Hits: 2}, &article{Title: "c", Hits: 6}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := repo.Sum("hits", nil); err != nil || v != 9 {
+		t.Error("sum", v, err)
+	}
+	if v, err := repo.Avg("hits", map[string]any{"": clause.Gt{Column: "hits", Value: 1}}); err != nil || v != 4 {
+		t.Error("avg", v, err)
+	}
+	//无记录时为零值
+	if v, err := repo.Sum("hits", map[string]any{"hits": 100}); err != nil || v != 0 {
+		t.Error("sum empty", v, err)
+	}
+	if v, err := utils.RepositoryMin[int](repo, "hits", nil); err != nil || v != 1 {
+		t.Error("min", v, err)
+	}
+	if v, err := utils.RepositoryMax[string](repo, "name", nil); err != nil || v != "c" {
+		t.Error("max by json name", v, err)
+	}
+	if v, err := utils.RepositoryPluck[string](repo, "title", map[string]any{"hits": []int{1, 6}}); err != nil || !reflect.DeepEqual(v, []string{"a", "c"}) {
+		t.Error("pluck", v, err)
+	}
+	if _, err := repo.Sum("unknown", nil); err == nil {
+		t.Error("unknown column should fail")
+	}
+}
+
+func TestRepositoryGroupBy(t *testing.T) {
+	db, _ := newTestDB(t, &order{})
+	repo := utils.NewRepository[order, int64](db)
+	if err := repo.Create(&order{Title: "a", Status: 1}, &order{Title: "a", Status: 2}, &order{Title: "b", Status: 4}, &order{Title: "c", Status: 8}); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := utils.RepositoryDistinct[string](repo, "name", nil); err != nil || len(v) != 3 {
+		t.Error("distinct", v, err)
+	}
+
+	//分组列按 json 名解析为 order_title
+	type titleStat struct {
+		OrderTitle string
+		Total      int64
+		Status     int64
+	}
+	stats, err := utils.RepositoryGroupBy[titleStat](repo, []string{"name"},
+		[]utils.RepositoryAggregate{utils.AggregateCount("total"), utils.AggregateSum("status", "status")},
+		map[string]any{"": clause.Lt{Column: "status", Value: 8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].OrderTitle < stats[j].OrderTitle })
+	if len(stats) != 2 || *stats[0] != (titleStat{"a", 2, 3}) || *stats[1] != (titleStat{"b", 1, 4}) {
+		t.Error("group by", stats)
+	}
+
+	if _, err = utils.RepositoryGroupBy[titleStat](repo, nil, nil, nil); err == nil {
+		t.Error("group by without columns should fail")
+	}
+	if _, err = utils.RepositoryGroupBy[titleStat](repo, []string{"name"}, []utils.RepositoryAggregate{{Func: "STDDEV", Column: "status", Alias: "x"}}, nil); err == nil {
+		t.Error("unsupported aggregate should fail")
+	}
+	if _, err = utils.RepositoryGroupBy[titleStat](repo, []string{"name"}, []utils.RepositoryAggregate{utils.AggregateSum("status", "")}, nil); err == nil {
+		t.Error("aggregate without alias should fail")
+	}
+}
@@ -0,0 +1,154 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+// auditRecorder 收集审计记录
+type auditRecorder struct {
+	entries []*utils.RepositoryAuditEntry
+}
+
+func (w *auditRecorder) WriteAudit(entries []*utils.RepositoryAuditEntry) error {
+	w.entries = append(w.entries, entries...)
+	return nil
+}
+
+func (w *auditRecorder) reset() []*utils.RepositoryAuditEntry {
+	entries := w.entries
+	w.entries = nil
+	return entries
+}
+
+func TestRepositoryAudit(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	writer := &auditRecorder{}
+	repo := utils.NewRepository[article, int64](db).EnableAudit(writer, func(ctx context.Context) string { return "tester" })
+
+	if err := repo.Create(&article{ID: 1, Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if entries := writer.reset(); len(entries) != 1 || entries[0].Action != utils.RepositoryActionCreate || entries[0].Operator != "tester" ||
+		entries[0].Changes["title"].New != "a" {
+		t.Error("create", entries)
+	}
+
+	if err := repo.Update(1, "title", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if entries := writer.reset(); len(entries) != 1 || len(entries[0].Changes) != 1 || entries[0].Changes["title"] != (utils.RepositoryAuditChange{Old: "a", New: "b"}) {
+		t.Error("update", entries)
+	}
+
+	//Upsert 单独记录, 冲突的记录只记录实际更新的列
+	if err := repo.Upsert([]*article{{ID: 1, Title: "c", Hits: 9}, {ID: 2, Title: "d"}}, nil, []string{"title"}); err != nil {
+		t.Fatal(err)
+	}
+	entries := writer.reset()
+	if len(entries) != 2 {
+		t.Fatal("upsert", entries)
+	}
+	for _, v := range entries {
+		if v.Action != utils.RepositoryActionUpsert {
+			t.Error("upsert action", v.Action)
+		}
+		switch v.PrimaryKey {
+		case int64(1):
+			if len(v.Changes) != 1 || v.Changes["title"] != (utils.RepositoryAuditChange{Old: "b", New: "c"}) {
+				t.Error("upsert updated", v.Changes)
+			}
+		case int64(2):
+			if v.Changes["title"].Old != nil || v.Changes["title"].New != "d" {
+				t.Error("upsert inserted", v.Changes)
+			}
+		default:
+			t.Error("upsert primary key", v.PrimaryKey)
+		}
+	}
+
+	if err := repo.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if entries = writer.reset(); len(entries) != 1 || entries[0].Action != utils.RepositoryActionDelete || entries[0].Changes["title"].Old != "d" {
+		t.Error("delete", entries)
+	}
+}
+
+func TestRepositoryAuditScope(t *testing.T) {
+	db, _ := newTestDB(t, &order{}, &post{})
+	writer := &auditRecorder{}
+	base := utils.NewRepository[order, int64](db).EnableAudit(writer, nil)
+	if err := base.Create(&order{ID: 1, TenantID: 7}, &order{ID: 2, TenantID: 8}, &order{ID: 3, TenantID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	writer.reset()
+
+	//旧数据按发起写操作的 Repository 的租户范围读取
+	repo := base.WithTenant(utils.RepositoryTenant{Column: "tenant_id", Resolve: utils.TenantFromContext(tenantKey{})}).WithContext(tenantContext(7))
+	if err := repo.UpdatesByConditions(map[string]any{"status": 0}, map[string]any{"status": 1}); err != nil {
+		t.Fatal(err)
+	}
+	entries := writer.reset()
+	if len(entries) != 2 {
+		t.Fatal("tenant scoped olds", entries)
+	}
+	for _, v := range entries {
+		if v.PrimaryKey == int64(2) {
+			t.Error("row of another tenant audited", v)
+		}
+	}
+
+	//Restore/ForceDelete 读取已软删除的记录
+	posts := utils.NewRepository[post, int64](db).EnableAudit(writer, nil)
+	if err := posts.Create(&post{ID: 1, Title: "a"}, &post{ID: 2, Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := posts.DeleteIn([]int64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	writer.reset()
+	if err := posts.Restore(1); err != nil {
+		t.Fatal(err)
+	}
+	if entries = writer.reset(); len(entries) != 1 || entries[0].Changes["deleted_at"].New != nil || entries[0].Changes["deleted_at"].Old == nil {
+		t.Error("restore", entries)
+	}
+	if err := posts.ForceDelete(2); err != nil {
+		t.Fatal(err)
+	}
+	if entries = writer.reset(); len(entries) != 1 || entries[0].PrimaryKey != int64(2) {
+		t.Error("force delete", entries)
+	}
+}
+
+func TestRepositoryAfterHooks(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+
+	//写入已完成, 前一个钩子失败时后续钩子仍然执行
+	errAudit, calls := errors.New("audit failed"), 0
+	repo.After(utils.RepositoryActionCreate, func(hc *utils.RepositoryHookContext[article]) error {
+		return errAudit
+	}).After(utils.RepositoryActionCreate, func(hc *utils.RepositoryHookContext[article]) error {
+		calls++
+		return nil
+	})
+	if err := repo.Create(&article{Title: "a"}); !errors.Is(err, errAudit) || calls != 1 {
+		t.Error("after hooks", calls, err)
+	}
+
+	//Before 钩子失败时中止写入
+	repo.Before(utils.RepositoryActionDelete, func(hc *utils.RepositoryHookContext[article]) error {
+		return errAudit
+	})
+	if err := repo.Delete(1); !errors.Is(err, errAudit) {
+		t.Error("before hook", err)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 1 {
+		t.Error("aborted delete", count)
+	}
+}
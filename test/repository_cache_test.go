@@ -0,0 +1,105 @@
+package test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jqqjj/go-utils"
+)
+
+func TestCachedRepository(t *testing.T) {
+	db, recorder := newTestDB(t, &article{})
+	store := utils.NewRepositoryCacheMemory(100)
+	repo := utils.NewCachedRepository(utils.NewRepository[article, int64](db), store, utils.RepositoryCacheConfig{NegativeTTL: time.Minute})
+	if err := repo.Create(&article{ID: 1, Title: "a"}, &article{ID: 2, Title: "b"}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+
+	//派生的 CachedRepository 共用缓存
+	for i := 0; i < 3; i++ {
+		if entity, err := repo.WithContext(context.Background()).Primary().Get(1); err != nil || entity.Title != "a" {
+			t.Fatal("get", entity, err)
+		}
+	}
+	if _, err := repo.Get(9); err != utils.ErrNotFound {
+		t.Error("get missing", err)
+	}
+	if _, err := repo.Get(9); err != utils.ErrNotFound {
+		t.Error("get cached missing", err)
+	}
+	if entities, err := repo.GetIn([]int64{2, 1, 9}); err != nil || len(entities) != 2 || entities[0].ID != 2 {
+		t.Error("get in", entities, err)
+	}
+	if sqls := recorder.reset(); len(sqls) != 3 {
+		t.Error("queries", sqls)
+	}
+
+	//经派生的 Repository 写入后失效
+	if err := repo.WithContext(context.Background()).Update(1, "title", "a2"); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.Get(1); entity.Title != "a2" {
+		t.Error("invalidate by id", entity)
+	}
+	if err := repo.Repository.WithContext(context.Background()).UpdatesByConditions(map[string]any{"title": "b"}, map[string]any{"hits": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.Get(2); entity.Hits != 3 {
+		t.Error("invalidate by conditions", entity)
+	}
+	if err := repo.Create(&article{ID: 9, Title: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(9); err != nil || entity.Title != "c" {
+		t.Error("invalidate negative cache", entity, err)
+	}
+
+	//同一 store 重复包装不重复注册失效钩子
+	deletes := &countingCacheStore{IRepositoryCacheStore: store}
+	again := utils.NewCachedRepository(repo.Repository, deletes, utils.RepositoryCacheConfig{})
+	utils.NewCachedRepository(repo.Repository, deletes, utils.RepositoryCacheConfig{})
+	if err := again.Delete(2); err != nil {
+		t.Fatal(err)
+	}
+	if deletes.calls != 1 {
+		t.Error("invalidate hooks registered", deletes.calls)
+	}
+}
+
+func TestCachedRepositoryTenant(t *testing.T) {
+	db, _ := newTestDB(t, &order{})
+	tenant := utils.RepositoryTenant{Column: "tenant_id", Resolve: utils.TenantFromContext(tenantKey{})}
+	repo := utils.NewCachedRepository(utils.NewRepository[order, int64](db), nil, utils.RepositoryCacheConfig{}).WithTenant(tenant)
+	if err := repo.WithContext(tenantContext(7)).Create(&order{ID: 1, Title: "a"}); err != nil {
+		t.Fatal(err)
+	}
+
+	//租户之间不共用缓存
+	if entity, err := repo.WithContext(tenantContext(7)).Get(1); err != nil || entity.Title != "a" {
+		t.Fatal("get", entity, err)
+	}
+	if _, err := repo.WithContext(tenantContext(8)).Get(1); err != utils.ErrNotFound {
+		t.Error("other tenant", err)
+	}
+
+	//写入失效所有租户下的缓存
+	if err := repo.WithoutTenant().Update(1, "name", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.WithContext(tenantContext(7)).Get(1); entity == nil || entity.Title != "b" {
+		t.Error("invalidate", entity)
+	}
+}
+
+// countingCacheStore 统计按前缀删除的次数
+type countingCacheStore struct {
+	utils.IRepositoryCacheStore
+	calls int
+}
+
+func (s *countingCacheStore) DeletePrefix(prefix string) {
+	s.calls++
+	s.IRepositoryCacheStore.DeletePrefix(prefix)
+}
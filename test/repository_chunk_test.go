@@ -0,0 +1,79 @@
+package test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+func newChunkRepository(t *testing.T) *utils.Repository[article, int64] {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+	entities := make([]*article, 0, 8)
+	for i := 1; i <= 8; i++ {
+		entities = append(entities, &article{Title: fmt.Sprint("a", i), Hits: i % 2})
+	}
+	if err := repo.Create(entities...); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func TestRepositoryChunk(t *testing.T) {
+	repo := newChunkRepository(t)
+
+	//按主键升序分批, 条件对每一批都生效
+	var batches [][]int64
+	err := repo.Chunk(context.Background(), map[string]any{"hits": 1}, 3, func(entities []*article) bool {
+		ids := make([]int64, 0, len(entities))
+		for _, v := range entities {
+			ids = append(ids, v.ID)
+		}
+		batches = append(batches, ids)
+		return true
+	})
+	if err != nil || !reflect.DeepEqual(batches, [][]int64{{1, 3, 5}, {7}}) {
+		t.Error("chunk", batches, err)
+	}
+
+	//返回 false 时停止
+	calls := 0
+	if err = repo.Chunk(context.Background(), nil, 3, func(entities []*article) bool {
+		calls++
+		return false
+	}); err != nil || calls != 1 {
+		t.Error("early stop", calls, err)
+	}
+
+	//取消 ctx 后不再读取下一批
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls = 0
+	err = repo.Chunk(ctx, nil, 3, func(entities []*article) bool {
+		calls++
+		cancel()
+		return true
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Error("cancel", calls, err)
+	}
+}
+
+func TestRepositoryEach(t *testing.T) {
+	repo := newChunkRepository(t)
+
+	var ids []int64
+	if err := repo.Each(context.Background(), nil, 3, func(entity *article) bool {
+		ids = append(ids, entity.ID)
+		return entity.ID < 5
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int64{1, 2, 3, 4, 5}) {
+		t.Error("each", ids)
+	}
+}
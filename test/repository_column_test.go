@@ -0,0 +1,110 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm/clause"
+)
+
+func TestRepositoryColumns(t *testing.T) {
+	db, recorder := newTestDB(t, &order{})
+	repo := utils.NewRepository[order, int64](db)
+	if err := repo.CreateInBatches([]*order{
+		{ID: 1, Title: "a", Status: 1},
+		{ID: 2, Title: "b", Status: 2},
+	}, 10); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+
+	//json 名与结构体字段名转换为列名
+	entities, err := repo.GetAllByConditions(map[string]any{"name": "a", "Status": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqls := recorder.reset()
+	if len(entities) != 1 || entities[0].ID != 1 {
+		t.Error("json and field names", entities)
+	}
+	if len(sqls) != 1 || !strings.Contains(sqls[0], "`order_title` = \"a\"") || !strings.Contains(sqls[0], "`status` = 1") {
+		t.Error("json and field names", sqls)
+	}
+
+	if count, err := repo.CountByConditions(map[string]any{"orders.status": 2}); err != nil || count != 1 {
+		t.Error("table column", count, err)
+	}
+
+	for _, conditions := range []map[string]any{
+		{"unknown": 1},
+		{"users.status": 1},
+		{"status = 1 or 1": 1},
+	} {
+		if _, err = repo.GetAllByConditions(conditions); err == nil {
+			t.Error("unknown column should fail", conditions)
+		}
+	}
+
+	if err = repo.Updates(1, map[string]any{"name": "c", "version": 0}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(1); err != nil || entity.Title != "c" {
+		t.Error("update json name", entity, err)
+	}
+	if err = repo.Updates(1, map[string]any{"version": 1, "x; drop": 1}); err == nil {
+		t.Error("unknown update column should fail")
+	}
+}
+
+func TestRepositoryExpressionColumns(t *testing.T) {
+	db, recorder := newTestDB(t, &order{})
+	repo := utils.NewRepository[order, int64](db)
+	if err := repo.CreateInBatches([]*order{
+		{ID: 1, Title: "sale", Status: 1},
+		{ID: 2, Title: "sale", Status: 2},
+		{ID: 3, Title: "other", Status: 3},
+	}, 10); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+
+	//过滤结构体的列名可以是 json 名
+	type filter struct {
+		Name   string `filter:"op=like"`
+		Status []int  `filter:"op=notin"`
+	}
+	conditions, err := utils.FilterConditions(filter{Name: "sal", Status: []int{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities, err := repo.GetAllByConditions(conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entities) != 1 || entities[0].ID != 1 {
+		t.Error("filter json name", entities)
+	}
+	if sqls := recorder.reset(); len(sqls) != 1 || !strings.Contains(sqls[0], "`order_title` LIKE") {
+		t.Error("filter json name", sqls)
+	}
+
+	//嵌套表达式中的列同样转换
+	count, err := repo.CountByConditions(map[string]any{"": clause.Or(
+		clause.Eq{Column: clause.Column{Name: "name"}, Value: "other"},
+		clause.Not(clause.Lt{Column: "Status", Value: 2}),
+	)})
+	if err != nil || count != 2 {
+		t.Error("nested expression", count, err)
+	}
+
+	for _, expr := range []clause.Expression{
+		clause.Gt{Column: clause.Column{Name: "unknown"}, Value: 1},
+		clause.Or(clause.Eq{Column: "status", Value: 1}, clause.Eq{Column: "unknown", Value: 1}),
+		clause.Not(clause.IN{Column: "users.id", Values: []any{1}}),
+	} {
+		if _, err = repo.CountByConditions(map[string]any{"": expr}); err == nil {
+			t.Error("unknown column in expression should fail", expr)
+		}
+	}
+}
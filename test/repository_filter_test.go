@@ -0,0 +1,115 @@
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+type orderFilter struct {
+	IDs     []int64 `filter:"column=id,op=in"`
+	Title   string  `filter:"column=order_title,op=like"`
+	Status  *int    `filter:"op=gte"`
+	Exclude []int   `filter:"column=status,op=notin"`
+	Deleted *bool   `filter:"column=version,op=null"`
+	Skip    string
+	Ignored string `filter:"-"`
+}
+
+func TestFilterConditions(t *testing.T) {
+	conditions, err := utils.FilterConditions((*orderFilter)(nil))
+	if err != nil || len(conditions) != 0 {
+		t.Error("nil filter", conditions, err)
+	}
+	if _, err = utils.FilterConditions(1); err == nil {
+		t.Error("non struct filter should fail")
+	}
+
+	//零值与 nil 指针不产生条件, 非 nil 指针指向的零值产生条件
+	zero, deleted := 0, false
+	conditions, err = utils.FilterConditions(&orderFilter{Status: &zero, Deleted: &deleted, Skip: "x", Ignored: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conditions) != 2 {
+		t.Error("conditions", conditions)
+	}
+	if _, ok := conditions["status gte"]; !ok {
+		t.Error("pointer to zero value", conditions)
+	}
+
+	conditions, err = utils.FilterConditions(orderFilter{IDs: []int64{1, 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := conditions["id"].([]int64); !ok || len(v) != 2 {
+		t.Error("in", conditions)
+	}
+
+	type badFilter struct {
+		Title string `filter:"op=between"`
+	}
+	if _, err = utils.FilterConditions(badFilter{Title: "a"}); err == nil {
+		t.Error("unsupported op should fail")
+	}
+}
+
+func TestFilterConditionsSQL(t *testing.T) {
+	db, recorder := newTestDB(t, &order{})
+	repo := utils.NewRepository[order, int64](db)
+	if err := repo.CreateInBatches([]*order{
+		{ID: 1, Title: `50%_off!`, Status: 1},
+		{ID: 2, Title: `500 off!`, Status: 1},
+		{ID: 3, Title: `50%_off`, Status: 2},
+		{ID: 4, Title: `sale 50%_off! now`, Status: 3},
+		{ID: 5, Title: `50%_off!`, Status: 0},
+	}, 10); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+
+	status, deleted := 1, false
+	conditions, err := utils.FilterConditions(&orderFilter{Title: `50%_off!`, Status: &status, Exclude: []int{3, 4}, Deleted: &deleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entities, err := repo.GetAllByConditions(conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//通配符与转义符按字面匹配
+	if len(entities) != 1 || entities[0].ID != 1 {
+		t.Error("like escape", entities)
+	}
+	sqls := recorder.reset()
+	if len(sqls) != 1 {
+		t.Fatal(sqls)
+	}
+	for _, v := range []string{
+		"`order_title` LIKE \"%50!%!_off!!%\" ESCAPE '!'",
+		"`status` >= 1",
+		"`status` NOT IN (3,4)",
+		"`version` IS NOT NULL",
+	} {
+		if !strings.Contains(sqls[0], v) {
+			t.Errorf("%s not in %s", v, sqls[0])
+		}
+	}
+
+	//包含匹配
+	if conditions, err = utils.FilterConditions(orderFilter{Title: "50%_off!"}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := repo.CountByConditions(conditions); err != nil || count != 3 {
+		t.Error("like contains", count, err)
+	}
+
+	deleted = true
+	if conditions, err = utils.FilterConditions(orderFilter{Deleted: &deleted}); err != nil {
+		t.Fatal(err)
+	}
+	if count, err := repo.CountByConditions(conditions); err != nil || count != 0 {
+		t.Error("is null", count, err)
+	}
+}
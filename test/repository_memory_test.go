@@ -0,0 +1,127 @@
+package test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+func TestRepositoryMemory(t *testing.T) {
+	//RepositoryMemory 可替代 *Repository 使用
+	var repo utils.IRepository[counter, uint64] = utils.NewRepositoryMemory[counter, uint64]()
+
+	a, b, c := &counter{Name: "a", Total: 1}, &counter{Name: "b", Total: 2}, &counter{Name: "c", Total: 3}
+	if err := repo.Create(a, b, c); err != nil {
+		t.Fatal(err)
+	}
+	if a.ID != 1 || b.ID != 2 || c.ID != 3 {
+		t.Error("auto increment", a.ID, b.ID, c.ID)
+	}
+	if err := repo.Create(&counter{ID: 2}); err == nil {
+		t.Error("duplicated primary key should fail")
+	}
+
+	if entity, err := repo.Get(2); err != nil || entity.Name != "b" {
+		t.Error("get", entity, err)
+	}
+	if _, err := repo.Get(9); err != utils.ErrNotFound {
+		t.Error("get missing", err)
+	}
+
+	//切片为 IN, nil 为 IS NULL, 支持 json 名
+	if entities, err := repo.GetAllByConditions(map[string]any{"total": []int{1, 3}, "note": nil}); err != nil || len(entities) != 2 {
+		t.Error("in", entities, err)
+	}
+	if count, err := repo.CountByConditions(map[string]any{"name": "a"}); err != nil || count != 1 {
+		t.Error("count", count, err)
+	}
+	if _, err := repo.GetAllByConditions(map[string]any{"unknown": 1}); err == nil {
+		t.Error("unknown column should fail")
+	}
+
+	if err := repo.Updates(1, map[string]any{"name": "a2", "total": int32(10)}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.Get(1); entity.Name != "a2" || entity.Total != 10 {
+		t.Error("updates", entity)
+	}
+	if err := repo.Updates(1, map[string]any{"id": 5}); err == nil {
+		t.Error("updating primary key should fail")
+	}
+
+	entities, total, err := repo.FindPage(utils.RepositoryPageQuery{Sorts: []utils.PaginationSort{{Column: "total", Descending: true}}, Limit: 2})
+	if err != nil || total != 3 || len(entities) != 2 || entities[0].ID != 1 || entities[1].ID != 3 {
+		t.Error("find page", entities, total, err)
+	}
+
+	if err = repo.DeleteIn([]uint64{2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := repo.GetAll(); len(all) != 1 {
+		t.Error("delete", all)
+	}
+}
+
+func TestRepositoryMemoryPrecision(t *testing.T) {
+	repo := utils.NewRepositoryMemory[counter, uint64]()
+
+	//超过 2^53 的整数转换为 float64 后无法区分
+	if err := repo.Create(&counter{ID: 1<<60 + 1, Total: 1<<53 + 1}, &counter{ID: 1 << 60, Total: 1 << 53, Rate: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []struct {
+		conditions map[string]any
+		want       int
+	}{
+		{map[string]any{"total": int64(1<<53 + 1)}, 1},
+		{map[string]any{"total": uint64(1 << 53)}, 1},
+		{map[string]any{"id": uint64(1<<60 + 1)}, 1},
+		{map[string]any{"id": []uint64{1 << 60, 1<<60 + 1}}, 2},
+		{map[string]any{"total": float64(1 << 53)}, 1},
+		{map[string]any{"rate": 0.5, "total": 1 << 53}, 1},
+		{map[string]any{"total": -1}, 0},
+	} {
+		if entities, err := repo.GetAllByConditions(v.conditions); err != nil || len(entities) != v.want {
+			t.Error(v.conditions, len(entities), err)
+		}
+	}
+
+	//自增从已有的最大主键继续
+	entity := &counter{}
+	if err := repo.Create(entity); err != nil || entity.ID != 1<<60+2 {
+		t.Error("next id", entity.ID, err)
+	}
+}
+
+func TestRepositoryMemorySave(t *testing.T) {
+	repo := utils.NewRepositoryMemory[counter, uint64]()
+
+	//同一主键的并发 Save 只会插入一次, 其余为更新
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- repo.Save(&counter{ID: 7, Total: int64(i)})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error("concurrent save", err)
+		}
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 1 {
+		t.Error("rows", count)
+	}
+
+	if err := repo.Save(&counter{ID: 7, Name: "x", Total: 100}, "name"); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.Get(7); entity.Name != "x" || entity.Total == 100 {
+		t.Error("save fields", entity)
+	}
+}
@@ -0,0 +1,132 @@
+package test
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+type membership struct {
+	GroupID int64 `gorm:"primaryKey;autoIncrement:false"`
+	UserID  int64 `gorm:"primaryKey;autoIncrement:false"`
+	Role    string
+}
+
+func (membership) PrimaryKey() string    { return "group_id" }
+func (membership) PrimaryKeys() []string { return []string{"group_id", "user_id"} }
+
+type membershipKey struct {
+	GroupID int64
+	UserID  int64
+}
+
+// uuidKey 与常见 UUID 库一样为 [16]byte
+type uuidKey [16]byte
+
+func (u uuidKey) Value() (driver.Value, error) {
+	return u[:], nil
+}
+
+func (u *uuidKey) Scan(value any) error {
+	b, ok := value.([]byte)
+	if !ok || len(b) != len(u) {
+		return fmt.Errorf("invalid uuid: %v", value)
+	}
+	copy(u[:], b)
+	return nil
+}
+
+type device struct {
+	ID   uuidKey `gorm:"primaryKey;type:blob"`
+	Name string
+}
+
+func (device) PrimaryKey() string { return "id" }
+
+func TestRepositoryCompositeKey(t *testing.T) {
+	db, _ := newTestDB(t, &membership{})
+	repo := utils.NewRepository[membership, membershipKey](db)
+	if err := repo.Create(&membership{1, 1, "owner"}, &membership{1, 2, "member"}, &membership{2, 1, "member"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if entity, err := repo.Get(membershipKey{1, 2}); err != nil || entity.Role != "member" {
+		t.Error("get", entity, err)
+	}
+	if _, err := repo.Get(membershipKey{2, 2}); err != utils.ErrNotFound {
+		t.Error("get missing", err)
+	}
+	if entities, err := repo.GetIn([]membershipKey{{1, 1}, {2, 1}}); err != nil || len(entities) != 2 {
+		t.Error("get in", entities, err)
+	}
+
+	if n, err := repo.UpdateAffected(membershipKey{2, 1}, "role", "owner"); err != nil || n != 1 {
+		t.Error("update", n, err)
+	}
+	if entity, _ := repo.Get(membershipKey{2, 1}); entity == nil || entity.Role != "owner" {
+		t.Error("updated", entity)
+	}
+	if entity, _ := repo.Get(membershipKey{1, 1}); entity == nil || entity.Role != "owner" {
+		t.Error("other rows unchanged", entity)
+	}
+
+	if err := repo.DeleteIn([]membershipKey{{1, 1}, {1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 1 {
+		t.Error("delete in", count)
+	}
+
+	if err := repo.Chunk(context.Background(), nil, 10, func([]*membership) bool { return true }); err == nil {
+		t.Error("chunk with composite key should fail")
+	}
+}
+
+func TestRepositoryUUIDKey(t *testing.T) {
+	db, _ := newTestDB(t, &device{})
+	repo := utils.NewRepository[device, uuidKey](db)
+	a, b := uuidKey{1, 2, 3}, uuidKey{4, 5, 6}
+	if err := repo.Create(&device{ID: a, Name: "a"}, &device{ID: b, Name: "b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if entity, err := repo.Get(b); err != nil || entity.Name != "b" || entity.ID != b {
+		t.Error("get", entity, err)
+	}
+	if entities, err := repo.GetIn([]uuidKey{a, b}); err != nil || len(entities) != 2 {
+		t.Error("get in", entities, err)
+	}
+	if err := repo.Update(a, "name", "a2"); err != nil {
+		t.Fatal(err)
+	}
+	if entity, _ := repo.Get(a); entity == nil || entity.Name != "a2" {
+		t.Error("update", entity)
+	}
+	if err := repo.Delete(b); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 1 {
+		t.Error("delete", count)
+	}
+}
+
+func TestRepositoryUnsignedKey(t *testing.T) {
+	db, _ := newTestDB(t, &counter{})
+	repo := utils.NewRepository[counter, uint64](db)
+	entity := &counter{Name: "a"}
+	if err := repo.Create(entity); err != nil || entity.ID == 0 {
+		t.Fatal(entity, err)
+	}
+	if v, err := repo.Get(entity.ID); err != nil || v.Name != "a" {
+		t.Error("get", v, err)
+	}
+	if err := repo.DeleteIn([]uint64{entity.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(entity.ID); err != utils.ErrNotFound {
+		t.Error("deleted", err)
+	}
+}
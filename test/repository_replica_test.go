@@ -0,0 +1,67 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm"
+)
+
+type articleResponse struct {
+	ID    int64
+	Title string
+}
+
+type articlePresenter struct{}
+
+func (articlePresenter) Present(entity *article) *articleResponse {
+	return &articleResponse{ID: entity.ID, Title: entity.Title}
+}
+
+func TestRepositoryReplicas(t *testing.T) {
+	primary, _ := newTestDB(t, &article{})
+	replicas := make([]*gorm.DB, 0, 2)
+	for _, title := range []string{"replica1", "replica2"} {
+		db, _ := newTestDB(t, &article{})
+		if err := db.Create(&article{ID: 1, Title: title}).Error; err != nil {
+			t.Fatal(err)
+		}
+		replicas = append(replicas, db)
+	}
+	repo := utils.NewRepositoryWithReplicas[article, int64](primary, replicas, &utils.RepositoryRoundRobinPolicy{})
+
+	//写操作使用主库
+	if err := repo.Create(&article{ID: 1, Title: "primary"}); err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	if primary.Model(&article{}).Count(&count); count != 1 {
+		t.Error("write to primary", count)
+	}
+
+	//读操作按策略轮流使用副本
+	var titles []string
+	for i := 0; i < 3; i++ {
+		entity, err := repo.WithContext(context.Background()).Get(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, entity.Title)
+	}
+	if titles[0] == titles[1] || titles[0] != titles[2] || titles[0] == "primary" {
+		t.Error("round robin", titles)
+	}
+	resp, err := utils.NewPagination[article, int64, articleResponse](repo, nil, articlePresenter{}).Paginate(1, 10)
+	if err != nil || len(resp.Items) != 1 || resp.Items[0].Title == "primary" {
+		t.Error("paginate from replica", resp, err)
+	}
+
+	//Primary() 读写都使用主库
+	if entity, err := repo.Primary().Get(1); err != nil || entity.Title != "primary" {
+		t.Error("read your writes", entity, err)
+	}
+	if entity, err := repo.Primary().WithContext(context.Background()).Get(1); err != nil || entity.Title != "primary" {
+		t.Error("primary with context", entity, err)
+	}
+}
@@ -0,0 +1,80 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm"
+)
+
+type post struct {
+	ID        int64 `gorm:"primaryKey"`
+	Title     string
+	DeletedAt gorm.DeletedAt
+}
+
+func (post) PrimaryKey() string { return "id" }
+
+type postResponse struct {
+	ID int64
+}
+
+type postPresenter struct{}
+
+func (postPresenter) Present(entity *post) *postResponse {
+	return &postResponse{ID: entity.ID}
+}
+
+func TestRepositorySoftDelete(t *testing.T) {
+	db, _ := newTestDB(t, &post{})
+	repo := utils.NewRepository[post, int64](db)
+	if err := repo.Create(&post{Title: "a"}, &post{Title: "b"}, &post{Title: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.DeleteIn([]int64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	count := func(r *utils.Repository[post, int64]) int64 {
+		count, err := r.CountByConditions(map[string]any{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+	if count(repo) != 1 || count(repo.WithTrashed()) != 3 || count(repo.OnlyTrashed()) != 2 {
+		t.Error("count", count(repo), count(repo.WithTrashed()), count(repo.OnlyTrashed()))
+	}
+	if _, err := repo.Get(1); err != utils.ErrNotFound {
+		t.Error("get trashed", err)
+	}
+	if entity, err := repo.WithTrashed().Get(1); err != nil || !entity.DeletedAt.Valid {
+		t.Error("get with trashed", entity, err)
+	}
+	if entities, err := repo.OnlyTrashed().GetAll(); err != nil || len(entities) != 2 {
+		t.Error("get only trashed", entities, err)
+	}
+	resp, err := utils.NewPagination[post, int64, postResponse](repo.OnlyTrashed(), nil, postPresenter{}).Paginate(1, 10)
+	if err != nil || resp.Total != 2 {
+		t.Error("paginate only trashed", resp, err)
+	}
+
+	if err = repo.Restore(1); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(1); err != nil || entity.DeletedAt.Valid {
+		t.Error("restore", entity, err)
+	}
+
+	//ForceDelete 同时删除已软删除的记录
+	if err = repo.ForceDelete(2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if count(repo.WithTrashed()) != 1 {
+		t.Error("force delete", count(repo.WithTrashed()))
+	}
+
+	if err = utils.NewRepository[article, int64](db).Restore(1); err == nil {
+		t.Error("restore without DeletedAt should fail")
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+type orderResponse struct {
+	ID int64
+}
+
+type orderPresenter struct{}
+
+func (orderPresenter) Present(entity *order) *orderResponse {
+	return &orderResponse{ID: entity.ID}
+}
+
+func newTenantRepository(t *testing.T) (*utils.Repository[order, int64], *sqlRecorder) {
+	db, recorder := newTestDB(t, &order{})
+	base := utils.NewRepository[order, int64](db)
+	if err := base.Create(&order{ID: 1, TenantID: 7}, &order{ID: 2, TenantID: 8}, &order{ID: 3, TenantID: 7}); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+	return base.WithTenant(utils.RepositoryTenant{Column: "tenant_id", Resolve: utils.TenantFromContext(tenantKey{})}), recorder
+}
+
+func TestRepositoryTenant(t *testing.T) {
+	tenants, _ := newTenantRepository(t)
+	repo := tenants.WithContext(tenantContext(7))
+
+	if count, err := repo.CountByConditions(map[string]any{}); err != nil || count != 2 {
+		t.Error("count", count, err)
+	}
+	if _, err := repo.Get(2); err != utils.ErrNotFound {
+		t.Error("get row of another tenant", err)
+	}
+	resp, err := utils.NewPagination[order, int64, orderResponse](repo, nil, orderPresenter{}).Paginate(1, 10)
+	if err != nil || resp.Total != 2 {
+		t.Error("paginate", resp, err)
+	}
+
+	//新增时写入租户列, 拒绝写入其他租户
+	entity := &order{Title: "a"}
+	if err = repo.Create(entity); err != nil || entity.TenantID != 7 {
+		t.Error("create", entity, err)
+	}
+	if err = repo.Create(&order{TenantID: 8}); err != utils.ErrCrossTenant {
+		t.Error("create for another tenant", err)
+	}
+	if err = repo.UpdatesByConditions(map[string]any{}, map[string]any{"tenant_id": 8}); err != utils.ErrCrossTenant {
+		t.Error("move to another tenant", err)
+	}
+	if err = repo.Save(&order{ID: 2, TenantID: 7, Version: 1}); err != utils.ErrCrossTenant {
+		t.Error("save row of another tenant", err)
+	}
+
+	//改/删只作用于本租户
+	if err = repo.UpdateAll("status", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.DeleteIn([]int64{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := tenants.WithoutTenant().Get(2); err != nil || v.Status != 0 {
+		t.Error("row of another tenant changed", v, err)
+	}
+
+	if _, err = tenants.WithContext(context.Background()).GetAll(); err == nil {
+		t.Error("missing tenant should fail")
+	}
+}
+
+func TestRepositoryUpsertTenant(t *testing.T) {
+	tenants, recorder := newTenantRepository(t)
+	repo := tenants.WithContext(tenantContext(7))
+
+	if err := repo.Upsert([]*order{{ID: 1, Title: "a", Version: 1}, {Title: "b"}}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	sqls := recorder.reset()
+	if len(sqls) != 2 {
+		t.Fatal("upsert sql", sqls)
+	}
+	//先检查冲突主键是否属于其他租户, 新记录不参与检查; 冲突时不更新租户列
+	if sqls[0] != "SELECT count(*) FROM `orders` WHERE `orders`.`id` = 1 AND `orders`.`tenant_id` <> 7" {
+		t.Error("cross tenant check", sqls[0])
+	}
+	if v, err := repo.Get(1); err != nil || v.Title != "a" || v.TenantID != 7 {
+		t.Error("upserted", v, err)
+	}
+
+	if err := repo.Upsert([]*order{{ID: 2, Title: "x"}}, nil, nil); err != utils.ErrCrossTenant {
+		t.Error("conflict with row of another tenant", err)
+	}
+	if err := repo.Upsert([]*order{{ID: 1, TenantID: 8}}, nil, nil); err != utils.ErrCrossTenant {
+		t.Error("entity of another tenant", err)
+	}
+	if err := repo.Upsert([]*order{{ID: 1}}, nil, []string{"tenant_id"}); err == nil {
+		t.Error("upserting only the tenant column should fail")
+	}
+	if v, _ := tenants.WithoutTenant().Get(2); v == nil || v.Title != "" || v.TenantID != 8 {
+		t.Error("row of another tenant changed", v)
+	}
+}
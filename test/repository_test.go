@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/jqqjj/go-utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type article struct {
+	ID    int64  `gorm:"primaryKey"`
+	Title string `gorm:"uniqueIndex" json:"name"`
+	Hits  int
+}
+
+func (article) PrimaryKey() string { return "id" }
+
+type order struct {
+	ID       int64  `gorm:"primaryKey" json:"id"`
+	TenantID int64  `json:"tenant_id"`
+	Title    string `gorm:"column:order_title" json:"name"`
+	Status   int    `json:"status"`
+	Version  int    `json:"version"`
+}
+
+func (order) PrimaryKey() string    { return "id" }
+func (order) VersionColumn() string { return "version" }
+
+type tenantKey struct{}
+
+func tenantContext(tenant int64) context.Context {
+	return context.WithValue(context.Background(), tenantKey{}, tenant)
+}
+
+// sqlRecorder 记录执行的 SQL
+type sqlRecorder struct {
+	logger.Interface
+	mux  sync.Mutex
+	sqls []string
+}
+
+func (l *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.sqls = append(l.sqls, sql)
+}
+
+func (l *sqlRecorder) reset() []string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	sqls := l.sqls
+	l.sqls = nil
+	return sqls
+}
+
+// newTestDB 打开独立的 SQLite 内存库并建表
+func newTestDB(t *testing.T, models ...any) (*gorm.DB, *sqlRecorder) {
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: recorder})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//每个连接是独立的内存库, 只保留一个连接
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDB.Close() })
+	if err = db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	recorder.reset()
+	return db, recorder
+}
+
+func TestRepositoryUpsert(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+
+	if err := repo.Create(&article{ID: 1, Title: "a", Hits: 5}); err != nil {
+		t.Fatal(err)
+	}
+	//冲突时只更新指定列, 新记录直接插入
+	if err := repo.Upsert([]*article{{ID: 1, Title: "b", Hits: 9}, {ID: 2, Title: "c", Hits: 1}}, []string{"id"}, []string{"name"}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(1); err != nil || entity.Title != "b" || entity.Hits != 5 {
+		t.Error("updated on conflict", entity, err)
+	}
+	if entity, err := repo.Get(2); err != nil || entity.Title != "c" || entity.Hits != 1 {
+		t.Error("inserted", entity, err)
+	}
+
+	//未指定更新列时更新所有非主键列
+	if err := repo.Upsert([]*article{{ID: 1, Title: "d", Hits: 7}}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(1); err != nil || entity.Title != "d" || entity.Hits != 7 {
+		t.Error("update all on conflict", entity, err)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 2 {
+		t.Error("rows", count)
+	}
+
+	if err := repo.Upsert([]*article{{ID: 1}}, nil, []string{"unknown"}); err == nil {
+		t.Error("unknown update column should fail")
+	}
+}
+
+func TestRepositoryCreateInBatches(t *testing.T) {
+	db, recorder := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+
+	entities := make([]*article, 0, 5)
+	for _, v := range []string{"a", "b", "c", "d", "e"} {
+		entities = append(entities, &article{Title: v})
+	}
+	if err := repo.CreateInBatches(entities, 2); err != nil {
+		t.Fatal(err)
+	}
+	inserts := 0
+	for _, v := range recorder.reset() {
+		if strings.HasPrefix(v, "INSERT") {
+			inserts++
+		}
+	}
+	if inserts != 3 {
+		t.Error("batches", inserts)
+	}
+	if entities[0].ID == 0 || entities[4].ID == 0 {
+		t.Error("primary keys not assigned", entities[0].ID, entities[4].ID)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 5 {
+		t.Error("rows", count)
+	}
+
+	recorder.reset()
+	if err := repo.CreateInBatches(nil, 2); err != nil {
+		t.Error(err)
+	}
+	if sqls := recorder.reset(); len(sqls) != 0 {
+		t.Error("empty batches", sqls)
+	}
+}
+
+func TestRepositoryCreateIgnore(t *testing.T) {
+	db, _ := newTestDB(t, &article{})
+	repo := utils.NewRepository[article, int64](db)
+
+	if err := repo.Create(&article{ID: 1, Title: "a", Hits: 1}); err != nil {
+		t.Fatal(err)
+	}
+	//主键与唯一索引冲突的记录被忽略, 其余照常插入
+	if err := repo.CreateIgnore(&article{ID: 1, Title: "x", Hits: 2}, &article{ID: 2, Title: "a"}, &article{ID: 3, Title: "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if entity, err := repo.Get(1); err != nil || entity.Title != "a" || entity.Hits != 1 {
+		t.Error("conflicting row changed", entity, err)
+	}
+	if count, _ := repo.CountByConditions(map[string]any{}); count != 2 {
+		t.Error("rows", count)
+	}
+	if err := repo.Create(&article{ID: 3, Title: "d"}); err == nil {
+		t.Error("plain create should fail on conflict")
+	}
+}
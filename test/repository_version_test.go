@@ -0,0 +1,105 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+// ticket 主键由客户端生成
+type ticket struct {
+	Code    string `gorm:"primaryKey"`
+	Title   string
+	Version int
+}
+
+func (ticket) PrimaryKey() string    { return "code" }
+func (ticket) VersionColumn() string { return "version" }
+
+func TestRepositoryVersionSave(t *testing.T) {
+	db, _ := newTestDB(t, &order{}, &ticket{})
+	repo := utils.NewRepository[order, int64](db)
+
+	entity := &order{Title: "a"}
+	if err := repo.Save(entity); err != nil || entity.ID == 0 || entity.Version != 1 {
+		t.Fatal("save new", entity, err)
+	}
+	stale := *entity
+	entity.Title = "b"
+	if err := repo.Save(entity); err != nil || entity.Version != 2 {
+		t.Fatal("save", entity, err)
+	}
+	//过期的副本保存失败且版本不变
+	stale.Title = "c"
+	if err := repo.Save(&stale); err != utils.ErrStaleEntity || stale.Version != 1 {
+		t.Error("stale save", stale, err)
+	}
+	if v, _ := repo.Get(entity.ID); v.Title != "b" || v.Version != 2 {
+		t.Error("saved", v)
+	}
+
+	//客户端生成主键的新记录直接插入
+	tickets := utils.NewRepository[ticket, string](db)
+	if err := tickets.Save(&ticket{Code: "t1", Title: "a"}); err != nil {
+		t.Fatal("save client key", err)
+	}
+	if v, err := tickets.Get("t1"); err != nil || v.Title != "a" || v.Version != 1 {
+		t.Error("inserted", v, err)
+	}
+	if err := tickets.Save(&ticket{Code: "t1", Title: "b", Version: 1}); err != nil {
+		t.Error("update client key", err)
+	}
+	if err := tickets.Save(&ticket{Code: "t1", Title: "c", Version: 1}); err != utils.ErrStaleEntity {
+		t.Error("stale client key", err)
+	}
+}
+
+func TestRepositoryVersionUpdates(t *testing.T) {
+	db, recorder := newTestDB(t, &order{})
+	repo := utils.NewRepository[order, int64](db)
+	if err := repo.Create(&order{ID: 1, Version: 1}, &order{ID: 2, Version: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.Updates(1, map[string]any{"status": 1}); err == nil {
+		t.Error("updates without expected version should fail")
+	}
+	if err := repo.Updates(1, map[string]any{"status": 1, "version": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Updates(1, map[string]any{"status": 2, "version": 1}); err != utils.ErrStaleEntity {
+		t.Error("stale updates", err)
+	}
+	if _, err := repo.Strict().UpdatesAffected(9, map[string]any{"status": 2, "version": 1}); err != utils.ErrNotFound {
+		t.Error("strict updates missing", err)
+	}
+	if v, _ := repo.Get(1); v.Status != 1 || v.Version != 2 {
+		t.Error("updated", v)
+	}
+
+	//其他更新方法同样自增版本
+	for name, fn := range map[string]func() error{
+		"Update":   func() error { return repo.Update(1, "status", 3) },
+		"UpdateIn": func() error { return repo.UpdateIn([]int64{1, 2}, "status", 3) },
+		"UpdatesByConditions": func() error {
+			return repo.UpdatesByConditions(map[string]any{"id": 1}, map[string]any{"status": 4})
+		},
+		"UpdateAll": func() error { return repo.UpdateAll("status", 5) },
+	} {
+		before, _ := repo.Get(1)
+		if err := fn(); err != nil {
+			t.Fatal(name, err)
+		}
+		if after, _ := repo.Get(1); after.Version != before.Version+1 {
+			t.Error(name, "must bump version", before.Version, after.Version)
+		}
+	}
+	recorder.reset()
+
+	if err := repo.UpdatesIn([]int64{1}, map[string]any{"version": 1}); err == nil {
+		t.Error("version column can not be updated directly")
+	}
+	if sqls := recorder.reset(); len(sqls) != 0 {
+		t.Error("rejected update executed", sqls)
+	}
+}
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jqqjj/go-utils"
+)
+
+func TestSliceStructField(t *testing.T) {
+	counters := []*counter{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "a"}}
+
+	ids, err := utils.SliceExtractStructField[*counter, uint64](counters, "ID")
+	if err != nil || !reflect.DeepEqual(ids, []uint64{1, 2, 3}) {
+		t.Error("extract", ids, err)
+	}
+	index, err := utils.SliceStructIndex[*counter, uint64](counters, "ID")
+	if err != nil || len(index) != 3 || index[2] != counters[1] {
+		t.Error("index", index, err)
+	}
+	group, err := utils.SliceStructGroup[counter, string]([]counter{*counters[0], *counters[1], *counters[2]}, "Name")
+	if err != nil || len(group["a"]) != 2 || len(group["b"]) != 1 {
+		t.Error("group", group, err)
+	}
+
+	if _, err = utils.SliceExtractStructField[*counter, string](counters, "ID"); err == nil {
+		t.Error("mismatched field type should fail")
+	}
+	if _, err = utils.SliceStructIndex[*counter, uint64](counters, "Missing"); err == nil {
+		t.Error("missing field should fail")
+	}
+	if _, err = utils.SliceStructGroup[int, int]([]int{1}, "ID"); err == nil {
+		t.Error("non struct should fail")
+	}
+}
@@ -0,0 +1,61 @@
+// app.js is large enough to be gzip-compressed on the fly
+console.log('line 0: the quick brown fox jumps over the lazy dog');
+console.log('line 1: the quick brown fox jumps over the lazy dog');
+console.log('line 2: the quick brown fox jumps over the lazy dog');
+console.log('line 3: the quick brown fox jumps over the lazy dog');
+console.log('line 4: the quick brown fox jumps over the lazy dog');
+console.log('line 5: the quick brown fox jumps over the lazy dog');
+console.log('line 6: the quick brown fox jumps over the lazy dog');
+console.log('line 7: the quick brown fox jumps over the lazy dog');
+console.log('line 8: the quick brown fox jumps over the lazy dog');
+console.log('line 9: the quick brown fox jumps over the lazy dog');
+console.log('line 10: the quick brown fox jumps over the lazy dog');
+console.log('line 11: the quick brown fox jumps over the lazy dog');
+console.log('line 12: the quick brown fox jumps over the lazy dog');
+console.log('line 13: the quick brown fox jumps over the lazy dog');
+console.log('line 14: the quick brown fox jumps over the lazy dog');
+console.log('line 15: the quick brown fox jumps over the lazy dog');
+console.log('line 16: the quick brown fox jumps over the lazy dog');
+console.log('line 17: the quick brown fox jumps over the lazy dog');
+console.log('line 18: the quick brown fox jumps over the lazy dog');
+console.log('line 19: the quick brown fox jumps over the lazy dog');
+console.log('line 20: the quick brown fox jumps over the lazy dog');
+console.log('line 21: the quick brown fox jumps over the lazy dog');
+console.log('line 22: the quick brown fox jumps over the lazy dog');
+console.log('line 23: the quick brown fox jumps over the lazy dog');
+console.log('line 24: the quick brown fox jumps over the lazy dog');
+console.log('line 25: the quick brown fox jumps over the lazy dog');
+console.log('line 26: the quick brown fox jumps over the lazy dog');
+console.log('line 27: the quick brown fox jumps over the lazy dog');
+console.log('line 28: the quick brown fox jumps over the lazy dog');
+console.log('line 29: the quick brown fox jumps over the lazy dog');
+console.log('line 30: the quick brown fox jumps over the lazy dog');
+console.log('line 31: the quick brown fox jumps over the lazy dog');
+console.log('line 32: the quick brown fox jumps over the lazy dog');
+console.log('line 33: the quick brown fox jumps over the lazy dog');
+console.log('line 34: the quick brown fox jumps over the lazy dog');
+console.log('line 35: the quick brown fox jumps over the lazy dog');
+console.log('line 36: the quick brown fox jumps over the lazy dog');
+console.log('line 37: the quick brown fox jumps over the lazy dog');
+console.log('line 38: the quick brown fox jumps over the lazy dog');
+console.log('line 39: the quick brown fox jumps over the lazy dog');
+console.log('line 40: the quick brown fox jumps over the lazy dog');
+console.log('line 41: the quick brown fox jumps over the lazy dog');
+console.log('line 42: the quick brown fox jumps over the lazy dog');
+console.log('line 43: the quick brown fox jumps over the lazy dog');
+console.log('line 44: the quick brown fox jumps over the lazy dog');
+console.log('line 45: the quick brown fox jumps over the lazy dog');
+console.log('line 46: the quick brown fox jumps over the lazy dog');
+console.log('line 47: the quick brown fox jumps over the lazy dog');
+console.log('line 48: the quick brown fox jumps over the lazy dog');
+console.log('line 49: the quick brown fox jumps over the lazy dog');
+console.log('line 50: the quick brown fox jumps over the lazy dog');
+console.log('line 51: the quick brown fox jumps over the lazy dog');
+console.log('line 52: the quick brown fox jumps over the lazy dog');
+console.log('line 53: the quick brown fox jumps over the lazy dog');
+console.log('line 54: the quick brown fox jumps over the lazy dog');
+console.log('line 55: the quick brown fox jumps over the lazy dog');
+console.log('line 56: the quick brown fox jumps over the lazy dog');
+console.log('line 57: the quick brown fox jumps over the lazy dog');
+console.log('line 58: the quick brown fox jumps over the lazy dog');
+console.log('line 59: the quick brown fox jumps over the lazy dog');